@@ -1,6 +1,7 @@
 package mocks3
 
 import (
+	"context"
 	"encoding/csv"
 	"math"
 	"math/rand"
@@ -8,8 +9,90 @@ import (
 	"strconv"
 
 	log "github.com/sirupsen/logrus"
+
+	utils "github.com/JooyoungPark73/mocks3/utils"
 )
 
+// benchmarkHeader names the columns of the benchmark CSVs. Failed requests
+// are recorded too, with their error in the last column.
+var benchmarkHeader = []string{
+	"Payload Size (Bytes)", "E2E Time (us)", "Target Time (us)",
+	"Connect Time (us)", "First Byte Time (us)", "Transfer Time (us)", "Bytes",
+	"Delay Time (us)", "Overshoot (us)", "Missed Target", "Attempts", "Backoff Time (us)", "Error",
+}
+
+func benchmarkRecord(payloadSize int64, result Result) []string {
+	errText := ""
+	if result.Err != nil {
+		errText = result.Err.Error()
+	}
+	return []string{
+		strconv.FormatInt(payloadSize, 10), strconv.FormatInt(result.E2ETime, 10), strconv.FormatInt(result.TargetTime, 10),
+		strconv.FormatInt(result.ConnectTime, 10), strconv.FormatInt(result.FirstByteTime, 10), strconv.FormatInt(result.TransferTime, 10), strconv.FormatInt(result.Bytes, 10),
+		strconv.FormatInt(result.DelayTime, 10), strconv.FormatInt(result.Overshoot, 10), strconv.FormatBool(result.MissedTarget),
+		strconv.Itoa(result.Attempts), strconv.FormatInt(result.BackoffTime, 10), errText,
+	}
+}
+
+// benchmarkStats adds up the outcomes of a benchmark run.
+type benchmarkStats struct {
+	commType       string
+	requests       int
+	failures       int
+	timeouts       int
+	missed         int
+	retries        int
+	worstOvershoot int64
+}
+
+// add records a request made of calls RPCs, such as the parts of a download.
+func (s *benchmarkStats) add(payloadSize int64, calls int, result Result) {
+	s.requests++
+	if result.Attempts > calls {
+		s.retries += result.Attempts - calls
+	}
+	switch {
+	case result.TimedOut:
+		log.Warnf("%s %d Bytes timed out after %d us", s.commType, payloadSize, result.E2ETime)
+		s.timeouts++
+	case result.Err != nil:
+		log.Warnf("%s %d Bytes failed: %v", s.commType, payloadSize, result.Err)
+		s.failures++
+	case result.MissedTarget:
+		log.Warnf("%s %d Bytes missed its target time %d us by %d us", s.commType, payloadSize, result.TargetTime, result.Overshoot)
+		s.missed++
+		if result.Overshoot > s.worstOvershoot {
+			s.worstOvershoot = result.Overshoot
+		}
+	}
+}
+
+// report logs the totals of the run. In strict mode a run where a request
+// missed its target time fails.
+func (s *benchmarkStats) report() {
+	if s.retries > 0 {
+		log.Infof("%s: %d retries over %d requests", s.commType, s.retries, s.requests)
+	}
+	if s.failures > 0 || s.timeouts > 0 {
+		log.Warnf("%s: %d of %d requests failed, %d timed out", s.commType, s.failures, s.requests, s.timeouts)
+	}
+	if s.missed == 0 {
+		return
+	}
+	if *utils.Strict {
+		log.Fatalf("%s: %d of %d requests missed their target time, worst overshoot %d us", s.commType, s.missed, s.requests, s.worstOvershoot)
+	}
+	log.Warnf("%s: %d of %d requests missed their target time, worst overshoot %d us", s.commType, s.missed, s.requests, s.worstOvershoot)
+}
+
+// requestContext bounds one benchmark request by the -timeout flag.
+func requestContext() (context.Context, context.CancelFunc) {
+	if *utils.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), *utils.Timeout)
+}
+
 func BenchmarkClientGet(testIteration int) {
 	// Setup any required resources (like a mock server)
 	csvFile, err := os.Create("get_benchmark.csv")
@@ -18,22 +101,34 @@ func BenchmarkClientGet(testIteration int) {
 	}
 	csvwriter := csv.NewWriter(csvFile)
 	defer csvwriter.Flush()
-	err = csvwriter.Write([]string{"Payload Size (Bytes)", "E2E Time (us)", "Target Time (us)"})
+	err = csvwriter.Write(benchmarkHeader)
 	if err != nil {
 		log.Fatalf("could not write to CSV file: %v", err)
 	}
 
+	stats := benchmarkStats{commType: "GET"}
 	for i := 0; i < testIteration; i++ {
 		randNumber := rand.Float64() * 29
 		payloadSize := int64(math.Pow(2, randNumber))
-		e2eTime, targetTime := ClientGet(payloadSize, "none")
-		err := csvwriter.Write([]string{strconv.FormatInt(payloadSize, 10), strconv.FormatInt(e2eTime, 10), strconv.FormatInt(targetTime, 10)})
+		ctx, cancel := requestContext()
+		var result Result
+		calls := 1
+		if *utils.Parts > 1 {
+			download, _ := ClientParallelGet(ctx, payloadSize, *utils.Parts, "none")
+			result, calls = download.Result, len(download.Parts)
+		} else {
+			result, _ = ClientGet(ctx, payloadSize, "none")
+		}
+		cancel()
+		stats.add(payloadSize, calls, result)
+		err = csvwriter.Write(benchmarkRecord(payloadSize, result))
 		if err != nil {
 			log.Fatalf("could not write to CSV file: %v", err)
 		}
 		csvwriter.Flush()
 	}
 	csvFile.Close()
+	stats.report()
 	// Teardown any resources
 }
 
@@ -45,20 +140,32 @@ func BenchmarkClientPut(testIteration int) {
 	}
 	csvwriter := csv.NewWriter(csvFile)
 	defer csvwriter.Flush()
-	err = csvwriter.Write([]string{"Payload Size (Bytes)", "E2E Time (us)", "Target Time (us)"})
+	err = csvwriter.Write(benchmarkHeader)
 	if err != nil {
 		log.Fatalf("could not write to CSV file: %v", err)
 	}
 
+	stats := benchmarkStats{commType: "PUT"}
 	for i := 0; i < testIteration; i++ {
 		randNumber := rand.Float64() * 29
 		payloadSize := int64(math.Pow(2, randNumber))
-		e2eTime, targetTime := ClientPut(payloadSize, "none")
-		err := csvwriter.Write([]string{strconv.FormatInt(payloadSize, 10), strconv.FormatInt(e2eTime, 10), strconv.FormatInt(targetTime, 10)})
+		ctx, cancel := requestContext()
+		var result Result
+		calls := 1
+		if *utils.PartSize > 0 {
+			upload, _ := ClientMultipartPut(ctx, "benchmark", "multipart", payloadSize, *utils.PartSize, *utils.Parts, "none")
+			result, calls = upload.Result, len(upload.Parts)
+		} else {
+			result, _ = ClientPut(ctx, payloadSize, "none")
+		}
+		cancel()
+		stats.add(payloadSize, calls, result)
+		err = csvwriter.Write(benchmarkRecord(payloadSize, result))
 		if err != nil {
 			log.Fatalf("could not write to CSV file: %v", err)
 		}
 		csvwriter.Flush()
 	}
 	csvFile.Close()
+	stats.report()
 }
@@ -0,0 +1,49 @@
+package mocks3
+
+import (
+	"fmt"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Checksums are those of the bytes of an object. ETag is the hex MD5, or for
+// an object assembled from parts the MD5 of the part MD5s followed by
+// -<number of parts>. CRC32C and SHA256 are base64 like the S3 headers.
+type Checksums = object.Checksums
+
+// parseChecksumAlgorithm reads the name of a checksum algorithm, CRC32C or
+// SHA256.
+func parseChecksumAlgorithm(name string) (pb.ChecksumAlgorithm, error) {
+	alg, ok := pb.ChecksumAlgorithm_value[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown checksum algorithm %q - choose from [CRC32C, SHA256]", name)
+	}
+	return pb.ChecksumAlgorithm(alg), nil
+}
+
+// verifyTrailer checks the checksums of the bytes a GetFile stream delivered
+// against those the server sent in its trailer. Only checksums both sides
+// have are compared, a mismatch fails with DataLoss.
+func verifyTrailer(trailer metadata.MD, got Checksums) error {
+	first := func(key string) string {
+		if values := trailer.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	for _, sum := range []struct{ name, got, want string }{
+		{"ETag", got.ETag, first("etag")},
+		{"CRC32C", got.CRC32C, first("x-amz-checksum-crc32c")},
+		{"SHA256", got.SHA256, first("x-amz-checksum-sha256")},
+	} {
+		if sum.got != "" && sum.want != "" && sum.got != sum.want {
+			return status.Errorf(codes.DataLoss, "%s of the bytes received is %s, the server sent %s", sum.name, sum.got, sum.want)
+		}
+	}
+	return nil
+}
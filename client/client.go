@@ -0,0 +1,173 @@
+package mocks3
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+	utils "github.com/JooyoungPark73/mocks3/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client talks to a mocks3 server over a pool of gRPC connections that are
+// dialed once and shared by every request, so measurements do not include
+// connection setup.
+type Client struct {
+	conns       []*grpc.ClientConn
+	next        uint32
+	retryPolicy RetryPolicy
+	checksum    pb.ChecksumAlgorithm
+	model       *latency.Model
+}
+
+type clientOptions struct {
+	addr        string
+	dialOptions []grpc.DialOption
+	poolSize    int
+	retryPolicy *RetryPolicy
+	checksum    string
+	model       *latency.Model
+}
+
+// Option configures a Client.
+type Option func(*clientOptions)
+
+// WithAddress sets the server address. It defaults to
+// $MOCKS3_SERVER_ADDRESS, then to the -addr flag.
+func WithAddress(addr string) Option {
+	return func(o *clientOptions) { o.addr = addr }
+}
+
+// WithDialOptions replaces the default insecure, blocking dial options.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *clientOptions) { o.dialOptions = opts }
+}
+
+// WithPoolSize sets how many connections requests are spread over, 1 by
+// default.
+func WithPoolSize(n int) Option {
+	return func(o *clientOptions) { o.poolSize = n }
+}
+
+// WithRetryPolicy sets how failed requests are retried. It defaults to the
+// policy of the -retry-* flags, which does not retry.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *clientOptions) { o.retryPolicy = &p }
+}
+
+// WithChecksumAlgorithm sets the checksum uploads send along with their
+// bytes for the server to verify, CRC32C or SHA256, on top of the MD5 of
+// objects. It defaults to the -checksum flag.
+func WithChecksumAlgorithm(name string) Option {
+	return func(o *clientOptions) { o.checksum = name }
+}
+
+// WithLatencyModel sets the model requests are padded out to. It defaults to
+// the model of the -latency-model flag.
+func WithLatencyModel(m *latency.Model) Option {
+	return func(o *clientOptions) { o.model = m }
+}
+
+// NewClient loads the latency model and dials the connection pool.
+func NewClient(opts ...Option) (*Client, error) {
+	o := clientOptions{
+		addr:        "none",
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock()},
+		poolSize:    1,
+		checksum:    *utils.Checksum,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.poolSize < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", o.poolSize)
+	}
+
+	if o.retryPolicy == nil {
+		p, err := FlagRetryPolicy()
+		if err != nil {
+			return nil, err
+		}
+		o.retryPolicy = &p
+	} else if err := o.retryPolicy.validate(); err != nil {
+		return nil, err
+	}
+
+	checksum, err := parseChecksumAlgorithm(o.checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.model == nil {
+		if o.model, err = utils.Model(); err != nil {
+			return nil, err
+		}
+	}
+
+	addr := resolveServerAddress(o.addr)
+	c := &Client{retryPolicy: *o.retryPolicy, checksum: checksum, model: o.model}
+	for i := 0; i < o.poolSize; i++ {
+		conn, err := grpc.Dial(addr, o.dialOptions...)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("could not connect to %s: %w", addr, err)
+		}
+		c.conns = append(c.conns, conn)
+	}
+	return c, nil
+}
+
+// conn picks the next connection of the pool.
+func (c *Client) conn() *grpc.ClientConn {
+	i := atomic.AddUint32(&c.next, 1)
+	return c.conns[int(i)%len(c.conns)]
+}
+
+// Close closes every connection of the pool.
+func (c *Client) Close() error {
+	var first error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// resolveServerAddress picks the explicit address, then MOCKS3_SERVER_ADDRESS,
+// then the -addr flag. "none" means no explicit address.
+func resolveServerAddress(addr string) string {
+	if addr != "none" {
+		return addr
+	} else if _, ok := os.LookupEnv("MOCKS3_SERVER_ADDRESS"); ok {
+		return os.Getenv("MOCKS3_SERVER_ADDRESS")
+	}
+	return *utils.Addr
+}
+
+var (
+	sharedMu      sync.Mutex
+	sharedClients = make(map[string]*Client)
+)
+
+// sharedClient returns the client the package level functions use for addr,
+// dialing it on first use.
+func sharedClient(addr string) (*Client, error) {
+	addr = resolveServerAddress(addr)
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if c, ok := sharedClients[addr]; ok {
+		return c, nil
+	}
+	c, err := NewClient(WithAddress(addr))
+	if err != nil {
+		return nil, err
+	}
+	sharedClients[addr] = c
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+)
+
+// CopyObject duplicates srcBucket/srcKey as dstBucket/dstKey on the server,
+// without moving the bytes through the client, and describes the copy, which
+// keeps the metadata of the source. The target time is the modeled COPY
+// latency for the object size.
+func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) (Metadata, Result, error) {
+	return c.copyObject(ctx, &pb.CopyRequest{
+		Source:      &pb.ObjectRef{Bucket: srcBucket, Key: srcKey},
+		Destination: &pb.ObjectRef{Bucket: dstBucket, Key: dstKey},
+	})
+}
+
+// CopyObjectWithMetadata is CopyObject giving the copy meta instead. Copying
+// an object onto itself this way replaces its metadata.
+func (c *Client) CopyObjectWithMetadata(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, meta Metadata) (Metadata, Result, error) {
+	return c.copyObject(ctx, &pb.CopyRequest{
+		Source:          &pb.ObjectRef{Bucket: srcBucket, Key: srcKey},
+		Destination:     &pb.ObjectRef{Bucket: dstBucket, Key: dstKey},
+		ReplaceMetadata: true,
+		Metadata:        meta.proto(),
+	})
+}
+
+func (c *Client) copyObject(ctx context.Context, req *pb.CopyRequest) (Metadata, Result, error) {
+	var meta Metadata
+	result, err := c.retry(ctx, func() (Result, error) {
+		t := startTiming(0)
+		md, err := pb.NewFileServiceClient(c.conn()).CopyFile(ctx, req, t.callOption())
+		if err != nil {
+			return t.fail(fmt.Errorf("client.CopyFile Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		t.result.ObjectSize = md.GetSize()
+		t.result.TargetTime = c.model.GetTimeToSleep("COPY", md.GetSize()).Microseconds()
+		meta = metadataFrom(md)
+		log.Debugf("COPY: %s/%s to %s/%s, %d Bytes", req.GetSource().GetBucket(), req.GetSource().GetKey(),
+			req.GetDestination().GetBucket(), req.GetDestination().GetKey(), md.GetSize())
+		return t.finish(ctx)
+	})
+	return meta, result, err
+}
+
+// ClientCopyObject copies an object on the server through the shared client
+// for addr, see Client.CopyObject.
+func ClientCopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, addr string) (Metadata, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Metadata{}, Result{Err: err}, err
+	}
+	return c.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+}
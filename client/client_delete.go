@@ -0,0 +1,84 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeletedKey is the outcome of a batch delete for one key. Err keeps the gRPC
+// status of a failure and is nil when the key was deleted.
+type DeletedKey struct {
+	Key string
+	Err error
+}
+
+// DeleteObject removes bucket/key. Like S3 it succeeds when there is no such
+// object. The target time is the modeled DELETE latency.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) (Result, error) {
+	ref := &pb.ObjectRef{Bucket: bucket, Key: key}
+	return c.retry(ctx, func() (Result, error) {
+		t := startTiming(c.model.GetTimeToSleep("DELETE", 0).Microseconds())
+		if _, err := pb.NewFileServiceClient(c.conn()).DeleteFile(ctx, ref, t.callOption()); err != nil {
+			return t.fail(fmt.Errorf("client.DeleteFile Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		log.Debugf("DELETE: %s/%s", bucket, key)
+		return t.finish(ctx)
+	})
+}
+
+// DeleteObjects removes up to 1000 keys of bucket in one request and returns
+// the outcome for each key, in order. The error only reports a failure of the
+// whole request. The target time is the modeled BATCH_DELETE latency for the
+// number of keys.
+func (c *Client) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedKey, Result, error) {
+	req := &pb.DeleteRequest{Bucket: bucket, Keys: keys}
+	var deleted []DeletedKey
+	result, err := c.retry(ctx, func() (Result, error) {
+		t := startTiming(c.model.GetTimeToSleep("BATCH_DELETE", int64(len(keys))).Microseconds())
+		batch, err := pb.NewFileServiceClient(c.conn()).DeleteFiles(ctx, req, t.callOption())
+		if err != nil {
+			return t.fail(fmt.Errorf("client.DeleteFiles Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		deleted = make([]DeletedKey, len(batch.GetResults()))
+		for i, key := range batch.GetResults() {
+			deleted[i].Key = key.GetKey()
+			if code := codes.Code(key.GetCode()); code != codes.OK {
+				deleted[i].Err = status.Error(code, key.GetMessage())
+			}
+		}
+		log.Debugf("DELETE: %d keys of %s", len(keys), bucket)
+		return t.finish(ctx)
+	})
+	return deleted, result, err
+}
+
+// ClientDeleteObject removes bucket/key through the shared client for addr,
+// see Client.DeleteObject.
+func ClientDeleteObject(ctx context.Context, bucket, key string, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.DeleteObject(ctx, bucket, key)
+}
+
+// ClientDeleteObjects removes keys of bucket in one request through the
+// shared client for addr, see Client.DeleteObjects.
+func ClientDeleteObjects(ctx context.Context, bucket string, keys []string, addr string) ([]DeletedKey, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return nil, Result{Err: err}, err
+	}
+	return c.DeleteObjects(ctx, bucket, keys)
+}
@@ -3,17 +3,17 @@ package mocks3
 import (
 	"context"
 	"flag"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	object "github.com/JooyoungPark73/mocks3/object"
 	pb "github.com/JooyoungPark73/mocks3/proto"
 	utils "github.com/JooyoungPark73/mocks3/utils"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func init() {
@@ -35,56 +35,203 @@ func init() {
 	}
 }
 
-func ClientGet(size int64, addr string) (int64, int64) {
-	start := time.Now()
-	targetTime := utils.GetTimeToSleep("GET", size).Microseconds()
-
-	// get server address from environment variable
-	var serverAddress string
-	if addr != "none" {
-		serverAddress = addr
-	} else if _, ok := os.LookupEnv("MOCKS3_SERVER_ADDRESS"); ok {
-		serverAddress = os.Getenv("MOCKS3_SERVER_ADDRESS")
-	} else {
-		serverAddress = *utils.Addr
+// Get downloads a synthetic blob of size bytes and pads the request out to
+// the modeled latency. The bytes are checked against the checksum the server
+// ends the stream with, a mismatch fails with DataLoss. Errors keep the gRPC
+// status of the failure, see status.Code. The request, emulated delay and
+// retries included, ends with ctx.
+func (c *Client) Get(ctx context.Context, size int64) (Result, error) {
+	return c.GetRange(ctx, size, 0, 0)
+}
+
+// GetRange downloads the length bytes starting at offset of a synthetic
+// object of size bytes, or up to its end when length is zero. The target
+// time is derived from the range size.
+func (c *Client) GetRange(ctx context.Context, size, offset, length int64) (Result, error) {
+	return c.retry(ctx, func() (Result, error) { return c.get(ctx, size, offset, length) })
+}
+
+func (c *Client) get(ctx context.Context, size, offset, length int64) (Result, error) {
+	rangeSize := size - offset
+	if length > 0 && length < rangeSize {
+		rangeSize = length
 	}
+	t := startTiming(c.model.GetTimeToSleep("GET", rangeSize).Microseconds())
+
+	// gRPC Connection from the pool
+	fs := pb.NewFileServiceClient(c.conn())
 
-	// gRPC Connection
-	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	// Send the request
+	stream, err := fs.GetFile(ctx, &pb.FileSize{Size: size, Offset: offset, Length: length}, t.callOption())
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		return t.fail(fmt.Errorf("client.GetFile Cannot send request size: %w", err))
+	}
+	t.connected()
+	digest := object.NewDigester(false, false)
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			log.Debugf("GET: %d Bytes", t.result.Bytes)
+			break
+		}
+		if err != nil {
+			return t.fail(fmt.Errorf("could not get file from stream after %d Bytes: %w", t.result.Bytes, err))
+		}
+		if chunk.GetSize() > 0 {
+			t.result.ObjectSize = chunk.GetSize()
+		}
+		digest.Write(chunk.GetBlob())
+		t.received(len(chunk.GetBlob()))
+	}
+	if err := verifyTrailer(stream.Trailer(), digest.Sum()); err != nil {
+		return t.fail(fmt.Errorf("could not get file: %w", err))
 	}
-	defer conn.Close()
-	c := pb.NewFileServiceClient(conn)
-	GRPCConnectionEstablishTime := time.Since(start).Microseconds()
-	log.Debugf("GRPC Connection Time: %d us", GRPCConnectionEstablishTime)
+	t.transferred()
+
+	return t.finish(ctx)
+}
+
+// GetObject downloads a stored object and returns its bytes along with the
+// request timing. The target time is derived from the object size. The bytes
+// are checked against the checksums the server ends the stream with, which
+// for a whole object include its ETag and SHA-256, and a mismatch fails with
+// DataLoss.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) ([]byte, Result, error) {
+	return c.GetObjectRange(ctx, bucket, key, 0, 0)
+}
+
+// GetObjectRange downloads the length bytes starting at offset of a stored
+// object, or up to its end when length is zero. A range starting past the
+// end fails with OutOfRange. The target time is derived from the bytes
+// returned.
+func (c *Client) GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) ([]byte, Result, error) {
+	data, _, result, err := c.getObjectRetry(ctx, bucket, key, offset, length, nil)
+	return data, result, err
+}
+
+// GetObjectWithMetadata is GetObject also returning the object metadata,
+// which comes along with the first bytes.
+func (c *Client) GetObjectWithMetadata(ctx context.Context, bucket, key string) ([]byte, Metadata, Result, error) {
+	return c.getObjectRetry(ctx, bucket, key, 0, 0, nil)
+}
+
+func (c *Client) getObjectRetry(ctx context.Context, bucket, key string, offset, length int64, cond *pb.Preconditions) ([]byte, Metadata, Result, error) {
+	var (
+		data []byte
+		meta Metadata
+	)
+	result, err := c.retry(ctx, func() (Result, error) {
+		var (
+			r   Result
+			err error
+		)
+		data, meta, r, err = c.getObject(ctx, bucket, key, offset, length, cond)
+		return r, err
+	})
+	return data, meta, result, err
+}
+
+func (c *Client) getObject(ctx context.Context, bucket, key string, offset, length int64, cond *pb.Preconditions) ([]byte, Metadata, Result, error) {
+	t := startTiming(0)
+
+	// gRPC Connection from the pool
+	fs := pb.NewFileServiceClient(c.conn())
 
 	// Send the request
-	stream, err := c.GetFile(context.Background(), &pb.FileSize{Size: size})
+	stream, err := fs.GetFile(ctx, &pb.FileSize{Object: &pb.ObjectRef{Bucket: bucket, Key: key}, Offset: offset, Length: length, Preconditions: cond}, t.callOption())
 	if err != nil {
-		log.Fatalf("client.GetFile Cannot send request size: %v", err)
+		r, err := t.fail(fmt.Errorf("client.GetFile Cannot send request object: %w", err))
+		return nil, Metadata{}, r, err
 	}
-	recv_size := int64(0)
+	t.connected()
+	var (
+		data   []byte
+		meta   Metadata
+		digest = object.NewDigester(false, false)
+	)
 	for {
 		chunk, err := stream.Recv()
-		recv_size += int64(len(chunk.GetBlob()))
-		// log.Debugf("GET: recvd %d / %d Bytes \r", recv_size, size)
 		if err == io.EOF {
-			log.Debugf("GET: %d Bytes", recv_size)
+			log.Debugf("GET: %s/%s, %d Bytes from %d", bucket, key, len(data), offset)
 			break
 		}
 		if err != nil {
-			log.Fatalf("could not get file from stream: %v", err)
+			r, err := t.fail(fmt.Errorf("could not get object %s/%s from stream after %d Bytes: %w", bucket, key, len(data), err))
+			return nil, Metadata{}, r, err
+		}
+		data = append(data, chunk.GetBlob()...)
+		if chunk.GetSize() > 0 {
+			t.result.ObjectSize = chunk.GetSize()
+		}
+		if chunk.GetNotModified() {
+			t.result.NotModified = true
+		}
+		if chunk.GetMetadata() != nil {
+			meta = metadataFrom(chunk.GetMetadata())
+			if offset == 0 && length == 0 {
+				// The whole object can be checked against its ETag, unless
+				// it was assembled from parts, and its SHA-256.
+				sums := meta.Checksums
+				digest = object.NewDigester(sums.ETag != "" && !strings.Contains(sums.ETag, "-"), sums.SHA256 != "")
+			}
 		}
+		digest.Write(chunk.GetBlob())
+		t.received(len(chunk.GetBlob()))
+	}
+	if err := verifyTrailer(stream.Trailer(), digest.Sum()); err != nil {
+		r, err := t.fail(fmt.Errorf("could not get object %s/%s: %w", bucket, key, err))
+		return nil, Metadata{}, r, err
+	}
+	t.transferred()
+	t.result.TargetTime = c.model.GetTimeToSleep("GET", int64(len(data))).Microseconds()
+	if t.result.NotModified {
+		t.result.TargetTime = c.model.GetTimeToSleep("HEAD", 0).Microseconds()
+		log.Debugf("GET: %s/%s not modified", bucket, key)
 	}
-	commTime := time.Since(start).Microseconds() - GRPCConnectionEstablishTime
 
-	log.Debugf("Received blob size: %d Bytes, commTime: %d us", recv_size, commTime)
+	r, err := t.finish(ctx)
+	if err != nil {
+		return nil, Metadata{}, r, err
+	}
+	return data, meta, r, nil
+}
 
-	timeToSleep := time.Duration(targetTime-time.Since(start).Microseconds()) * time.Microsecond
-	time.Sleep(timeToSleep)
-	log.Debugf("Time to sleep: %d us, net sleep: %d us", targetTime, timeToSleep.Microseconds())
-	e2eTime := time.Since(start).Microseconds()
+// ClientGet downloads a synthetic blob of size bytes through the shared
+// client for addr, see Client.Get.
+func ClientGet(ctx context.Context, size int64, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.Get(ctx, size)
+}
+
+// ClientGetObject downloads bucket/key through the shared client for addr,
+// see Client.GetObject.
+func ClientGetObject(ctx context.Context, bucket, key string, addr string) ([]byte, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return nil, Result{Err: err}, err
+	}
+	return c.GetObject(ctx, bucket, key)
+}
 
-	return e2eTime, targetTime
+// ClientGetRange downloads part of a synthetic object through the shared
+// client for addr, see Client.GetRange.
+func ClientGetRange(ctx context.Context, size, offset, length int64, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.GetRange(ctx, size, offset, length)
+}
+
+// ClientGetObjectRange downloads part of bucket/key through the shared
+// client for addr, see Client.GetObjectRange.
+func ClientGetObjectRange(ctx context.Context, bucket, key string, offset, length int64, addr string) ([]byte, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return nil, Result{Err: err}, err
+	}
+	return c.GetObjectRange(ctx, bucket, key, offset, length)
 }
@@ -0,0 +1,82 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+)
+
+// Metadata describes a stored object. Uploads only send ContentType,
+// UserMetadata and StorageClass, the server fills in S3 defaults for the
+// empty ones and lower cases the user metadata keys. Checksums are those the
+// server computed when it stored the object.
+type Metadata struct {
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	UserMetadata map[string]string
+	StorageClass string
+	Checksums    Checksums
+}
+
+func (m Metadata) proto() *pb.ObjectMetadata {
+	return &pb.ObjectMetadata{ContentType: m.ContentType, UserMetadata: m.UserMetadata, StorageClass: m.StorageClass}
+}
+
+func metadataFrom(md *pb.ObjectMetadata) Metadata {
+	return Metadata{
+		Size:         md.GetSize(),
+		ContentType:  md.GetContentType(),
+		LastModified: md.GetLastModified().AsTime(),
+		UserMetadata: md.GetUserMetadata(),
+		StorageClass: md.GetStorageClass(),
+		Checksums:    object.ChecksumsFrom(md.GetChecksums()),
+	}
+}
+
+// HeadObject describes a stored object without downloading it, like the
+// size and digest check an image puller makes before a pull. The target time
+// is the modeled HEAD latency.
+func (c *Client) HeadObject(ctx context.Context, bucket, key string) (Metadata, Result, error) {
+	var meta Metadata
+	result, err := c.retry(ctx, func() (Result, error) {
+		var (
+			r   Result
+			err error
+		)
+		meta, r, err = c.headObject(ctx, bucket, key)
+		return r, err
+	})
+	return meta, result, err
+}
+
+func (c *Client) headObject(ctx context.Context, bucket, key string) (Metadata, Result, error) {
+	t := startTiming(c.model.GetTimeToSleep("HEAD", 0).Microseconds())
+	md, err := pb.NewFileServiceClient(c.conn()).HeadFile(ctx, &pb.ObjectRef{Bucket: bucket, Key: key}, t.callOption())
+	if err != nil {
+		r, err := t.fail(fmt.Errorf("client.HeadFile Failed: %w", err))
+		return Metadata{}, r, err
+	}
+	t.received(0)
+	t.transferred()
+	t.result.ObjectSize = md.GetSize()
+	log.Debugf("HEAD: %s/%s, %d Bytes", bucket, key, md.GetSize())
+
+	r, err := t.finish(ctx)
+	return metadataFrom(md), r, err
+}
+
+// ClientHeadObject describes bucket/key through the shared client for addr,
+// see Client.HeadObject.
+func ClientHeadObject(ctx context.Context, bucket, key string, addr string) (Metadata, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Metadata{}, Result{Err: err}, err
+	}
+	return c.HeadObject(ctx, bucket, key)
+}
@@ -0,0 +1,87 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+)
+
+// ListedObject is one object of a Listing.
+type ListedObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	StorageClass string
+}
+
+// Listing is one page of a bucket listing. When it is truncated, passing
+// NextContinuationToken to ListObjects fetches the next page.
+type Listing struct {
+	Objects               []ListedObject
+	CommonPrefixes        []string
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// ListObjects lists the objects of bucket whose key starts with prefix, with
+// the S3 ListObjectsV2 semantics: keys sharing the part after prefix up to
+// delimiter are rolled up into common prefixes, and at most maxKeys entries,
+// 1000 when zero, are returned from where continuationToken points. The
+// target time is the modeled LIST latency for the entries returned.
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix, delimiter, continuationToken string, maxKeys int) (Listing, Result, error) {
+	req := &pb.ListRequest{Bucket: bucket, Prefix: prefix, Delimiter: delimiter, ContinuationToken: continuationToken, MaxKeys: int32(maxKeys)}
+	var listing Listing
+	result, err := c.retry(ctx, func() (Result, error) {
+		var (
+			r   Result
+			err error
+		)
+		listing, r, err = c.listObjects(ctx, req)
+		return r, err
+	})
+	return listing, result, err
+}
+
+func (c *Client) listObjects(ctx context.Context, req *pb.ListRequest) (Listing, Result, error) {
+	t := startTiming(0)
+	list, err := pb.NewFileServiceClient(c.conn()).ListFiles(ctx, req, t.callOption())
+	if err != nil {
+		r, err := t.fail(fmt.Errorf("client.ListFiles Failed: %w", err))
+		return Listing{}, r, err
+	}
+	t.received(0)
+	t.transferred()
+	entries := len(list.GetObjects()) + len(list.GetCommonPrefixes())
+	t.result.TargetTime = c.model.GetTimeToSleep("LIST", int64(entries)).Microseconds()
+	log.Debugf("LIST: %s/%s, %d entries", req.GetBucket(), req.GetPrefix(), entries)
+
+	listing := Listing{
+		CommonPrefixes:        list.GetCommonPrefixes(),
+		IsTruncated:           list.GetIsTruncated(),
+		NextContinuationToken: list.GetNextContinuationToken(),
+	}
+	for _, obj := range list.GetObjects() {
+		listing.Objects = append(listing.Objects, ListedObject{
+			Key:          obj.GetKey(),
+			Size:         obj.GetSize(),
+			LastModified: obj.GetLastModified().AsTime(),
+			StorageClass: obj.GetStorageClass(),
+		})
+	}
+	r, err := t.finish(ctx)
+	return listing, r, err
+}
+
+// ClientListObjects lists a page of bucket through the shared client for
+// addr, see Client.ListObjects.
+func ClientListObjects(ctx context.Context, bucket, prefix, delimiter, continuationToken string, maxKeys int, addr string) (Listing, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Listing{}, Result{Err: err}, err
+	}
+	return c.ListObjects(ctx, bucket, prefix, delimiter, continuationToken, maxKeys)
+}
@@ -3,17 +3,16 @@ package mocks3
 import (
 	"context"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	object "github.com/JooyoungPark73/mocks3/object"
 	pb "github.com/JooyoungPark73/mocks3/proto"
 	utils "github.com/JooyoungPark73/mocks3/utils"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func init() {
@@ -35,63 +34,157 @@ func init() {
 	}
 }
 
-func ClientPut(size int64, addr string) (int64, int64) {
-	start := time.Now()
-	targetTime := utils.GetTimeToSleep("PUT", size).Microseconds()
-
-	// get server address from environment variable
-	var serverAddress string
-	if addr != "none" {
-		serverAddress = addr
-	} else if _, ok := os.LookupEnv("MOCKS3_SERVER_ADDRESS"); ok {
-		serverAddress = os.Getenv("MOCKS3_SERVER_ADDRESS")
-	} else {
-		serverAddress = *utils.Addr
-	}
+// Put uploads a synthetic blob of size bytes and pads the request out to the
+// modeled latency. The blob ends with its checksum, and the upload fails with
+// DataLoss when the server receives other bytes. Errors keep the gRPC status
+// of the failure. The request, emulated delay and retries included, ends
+// with ctx.
+func (c *Client) Put(ctx context.Context, size int64) (Result, error) {
+	return c.retry(ctx, func() (Result, error) { return c.put(ctx, size) })
+}
 
-	// gRPC Connection
-	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
-	if err != nil {
-		log.Fatalf("did not connect: %v", err)
-	}
-	defer conn.Close()
-	c := pb.NewFileServiceClient(conn)
-	stream, err := c.PutFile(context.Background())
+func (c *Client) put(ctx context.Context, size int64) (Result, error) {
+	t := startTiming(c.model.GetTimeToSleep("PUT", size).Microseconds())
+
+	// gRPC Connection from the pool
+	fs := pb.NewFileServiceClient(c.conn())
+	stream, err := fs.PutFile(ctx, t.callOption())
 	if err != nil {
-		log.Fatalf("client.PutFile Connection Failed: %v", err)
+		return t.fail(fmt.Errorf("client.PutFile Connection Failed: %w", err))
 	}
-	GRPCConnectionEstablishTime := time.Since(start).Microseconds()
+	t.connected()
 
 	// Generate a random blob
 	buffer := utils.CreateRandomObject(2 * 1024 * 1024)
-	creationTime := time.Since(start).Microseconds() - GRPCConnectionEstablishTime
-	log.Debugf("GRPC Connection Time: %d us, Creation Time: %d us", GRPCConnectionEstablishTime, creationTime)
 
-	// Send the blob
+	// Send the blob, then its checksums
+	digest := object.NewDigester(false, c.checksum == pb.ChecksumAlgorithm_SHA256)
 	for remaining := size; remaining > 0; remaining -= int64(len(buffer)) {
 		if remaining < int64(len(buffer)) {
 			buffer = buffer[:remaining]
 		}
-		if err := stream.Send(&pb.FileBlob{Blob: buffer}); err != nil {
+		chunk := &pb.FileBlob{Blob: buffer}
+		if remaining == size {
+			chunk.Size = size
+			chunk.ChecksumAlgorithm = c.checksum
+		}
+		digest.Write(buffer)
+		if err := stream.Send(chunk); err != nil {
+			if err == io.EOF {
+				// The server ended the stream, CloseAndRecv reports why
+				break
+			}
+			return t.fail(fmt.Errorf("client.PutFile Send Failed: %w", err))
+		}
+		t.sent(len(buffer))
+	}
+	if err := sendChecksums(stream, &pb.FileBlob{ChecksumAlgorithm: c.checksum}, digest); err != nil {
+		return t.fail(fmt.Errorf("client.PutFile Send Failed: %w", err))
+	}
+
+	r, err := stream.CloseAndRecv()
+	if err != nil {
+		return t.fail(fmt.Errorf("client.PutFile Recv Failed: %w", err))
+	}
+	t.received(0)
+	t.transferred()
+	log.Debugf("Sent blob size: %d Bytes", r.GetSize())
+
+	return t.finish(ctx)
+}
+
+// PutObject uploads data as bucket/key so a later GetObject can read it back.
+// It returns the request timing like Put. The server verifies the MD5 and
+// checksum of data and stores them with the object.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, data []byte) (Result, error) {
+	return c.PutObjectWithMetadata(ctx, bucket, key, data, Metadata{})
+}
+
+// PutObjectWithMetadata is PutObject storing meta along with the object.
+func (c *Client) PutObjectWithMetadata(ctx context.Context, bucket, key string, data []byte, meta Metadata) (Result, error) {
+	return c.retry(ctx, func() (Result, error) { return c.putObject(ctx, bucket, key, data, meta, nil) })
+}
+
+func (c *Client) putObject(ctx context.Context, bucket, key string, data []byte, meta Metadata, cond *pb.Preconditions) (Result, error) {
+	size := int64(len(data))
+	t := startTiming(c.model.GetTimeToSleep("PUT", size).Microseconds())
+
+	// gRPC Connection from the pool
+	fs := pb.NewFileServiceClient(c.conn())
+	stream, err := fs.PutFile(ctx, t.callOption())
+	if err != nil {
+		return t.fail(fmt.Errorf("client.PutFile Connection Failed: %w", err))
+	}
+	t.connected()
+
+	// Send the object, the first chunk names it, then its checksums
+	digest := object.NewDigester(true, c.checksum == pb.ChecksumAlgorithm_SHA256)
+	chunkSize := 2 * 1024 * 1024
+	for offset := 0; offset == 0 || offset < len(data); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := &pb.FileBlob{Blob: data[offset:end]}
+		if offset == 0 {
+			chunk.Object = &pb.ObjectRef{Bucket: bucket, Key: key}
+			chunk.Size = size
+			chunk.Metadata = meta.proto()
+			chunk.ChecksumAlgorithm = c.checksum
+			chunk.Preconditions = cond
+		}
+		digest.Write(chunk.Blob)
+		if err := stream.Send(chunk); err != nil {
 			if err == io.EOF {
+				// The server ended the stream, CloseAndRecv reports why
 				break
 			}
-			log.Fatalf("client.PutFile Send Failed: %v", err)
+			return t.fail(fmt.Errorf("client.PutFile Send Failed: %w", err))
 		}
+		t.sent(end - offset)
+	}
+	if err := sendChecksums(stream, &pb.FileBlob{}, digest); err != nil {
+		return t.fail(fmt.Errorf("client.PutFile Send Failed: %w", err))
 	}
 
 	r, err := stream.CloseAndRecv()
-	if err == io.EOF {
-		commTime := time.Since(start).Microseconds() - GRPCConnectionEstablishTime - creationTime
-		log.Debugf("Sent blob size: %d Bytes, commTime: %d us", r.GetSize(), commTime)
-	} else if err != nil {
-		log.Fatalf("client.PutFile Recv Failed: %v", err)
+	if err != nil {
+		return t.fail(fmt.Errorf("client.PutFile Recv Failed: %w", err))
 	}
+	t.received(0)
+	t.transferred()
+	log.Debugf("Sent object %s/%s size: %d Bytes", bucket, key, r.GetSize())
 
-	timeToSleep := time.Duration(targetTime-time.Since(start).Microseconds()) * time.Microsecond
-	time.Sleep(timeToSleep)
-	log.Debugf("Time to sleep: %d us, net sleep: %d us", targetTime, timeToSleep.Microseconds())
-	e2eTime := time.Since(start).Microseconds()
+	return t.finish(ctx)
+}
+
+// sendChecksums ends an upload with the checksums of the bytes sent, in last,
+// an otherwise empty chunk. An upload the server already ended is left for
+// CloseAndRecv to report.
+func sendChecksums(stream interface{ Send(*pb.FileBlob) error }, last *pb.FileBlob, digest *object.Digester) error {
+	last.Checksums = digest.Sum().Proto()
+	if err := stream.Send(last); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// ClientPut uploads a synthetic blob of size bytes through the shared client
+// for addr, see Client.Put.
+func ClientPut(ctx context.Context, size int64, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.Put(ctx, size)
+}
 
-	return e2eTime, targetTime
+// ClientPutObject uploads data as bucket/key through the shared client for
+// addr, see Client.PutObject.
+func ClientPutObject(ctx context.Context, bucket, key string, data []byte, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.PutObject(ctx, bucket, key, data)
 }
@@ -0,0 +1,53 @@
+package mocks3
+
+import (
+	"context"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+// Preconditions make a request depend on the stored object, like the S3
+// If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since
+// headers. IfMatch and IfNoneMatch list ETags separated by commas, or are *
+// to match any object. Zero fields are not evaluated.
+type Preconditions = object.Preconditions
+
+// GetObjectIf is GetObjectWithMetadata made conditional, for instance on
+// IfNoneMatch set to the ETag of a cached copy to revalidate it. When
+// IfNoneMatch or IfModifiedSince fails the object is unchanged: no bytes are
+// returned, only the metadata, Result.NotModified is set and the target time
+// is the modeled HEAD latency. Other failed preconditions fail with
+// FailedPrecondition.
+func (c *Client) GetObjectIf(ctx context.Context, bucket, key string, cond Preconditions) ([]byte, Metadata, Result, error) {
+	return c.getObjectRetry(ctx, bucket, key, 0, 0, cond.Proto())
+}
+
+// PutObjectIf is PutObjectWithMetadata made conditional. The server
+// evaluates IfMatch, IfNoneMatch and IfUnmodifiedSince atomically as it
+// stores the object, and fails with FailedPrecondition without storing it
+// when they do not hold. IfNoneMatch set to * only creates an object when
+// there is none, which makes it a lock. IfMatch on a missing object fails
+// with NotFound, and IfModifiedSince is ignored.
+func (c *Client) PutObjectIf(ctx context.Context, bucket, key string, data []byte, meta Metadata, cond Preconditions) (Result, error) {
+	return c.retry(ctx, func() (Result, error) { return c.putObject(ctx, bucket, key, data, meta, cond.Proto()) })
+}
+
+// ClientGetObjectIf downloads bucket/key unless cond fails through the
+// shared client for addr, see Client.GetObjectIf.
+func ClientGetObjectIf(ctx context.Context, bucket, key string, cond Preconditions, addr string) ([]byte, Metadata, Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return nil, Metadata{}, Result{Err: err}, err
+	}
+	return c.GetObjectIf(ctx, bucket, key, cond)
+}
+
+// ClientPutObjectIf uploads data as bucket/key if cond holds through the
+// shared client for addr, see Client.PutObjectIf.
+func ClientPutObjectIf(ctx context.Context, bucket, key string, data []byte, meta Metadata, cond Preconditions, addr string) (Result, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Result{Err: err}, err
+	}
+	return c.PutObjectIf(ctx, bucket, key, data, meta, cond)
+}
@@ -0,0 +1,199 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Download describes a GET split into ranged parts fetched concurrently.
+// Result sums the parts up: E2ETime is the wall clock of the whole download
+// and the other times are measured from its start, so TargetTime is when the
+// last part was due, FirstByteTime when the first byte of any part arrived
+// and DelayTime how long the download waited after its last byte. Bytes,
+// Attempts and BackoffTime add up over the parts, Overshoot is the worst one
+// and Err the first failure.
+type Download struct {
+	Result
+	Parts []Part
+}
+
+// Part is one ranged GET of a Download, or one part of an Upload.
+type Part struct {
+	Offset int64
+	Length int64
+	// Start is when the part was requested, in microseconds since the
+	// transfer started. The times of Result are measured from there, or from
+	// its last attempt when it was retried.
+	Start int64
+	Result
+}
+
+// ParallelGet downloads a synthetic object of size bytes as parts ranged
+// GETs of about equal length, all in flight at once over the pooled
+// connections. Each part is paced to the model for its own range size.
+func (c *Client) ParallelGet(ctx context.Context, size int64, parts int) (Download, error) {
+	if parts < 1 {
+		return Download{}, fmt.Errorf("a download needs at least 1 part, got %d", parts)
+	}
+	partSize := (size + int64(parts) - 1) / int64(parts)
+	if partSize == 0 {
+		partSize = 1
+	}
+	t := newTransfer()
+	t.run(ctx, size, 0, partSize, parts, func(ctx context.Context, offset, length int64) (Result, error) {
+		return c.GetRange(ctx, size, offset, length)
+	})
+	result, partResults, err := t.finish()
+	return Download{Result: result, Parts: partResults}, err
+}
+
+// ParallelGetObject downloads a stored object in ranged GETs of partSize
+// bytes, with at most concurrency of them in flight. Like the S3 transfer
+// manager it learns the object size from the first part before fetching the
+// rest.
+func (c *Client) ParallelGetObject(ctx context.Context, bucket, key string, partSize int64, concurrency int) ([]byte, Download, error) {
+	if partSize < 1 || concurrency < 1 {
+		return nil, Download{}, fmt.Errorf("a download needs a positive part size and concurrency, got %d and %d", partSize, concurrency)
+	}
+	t := newTransfer()
+	first, result, err := c.GetObjectRange(ctx, bucket, key, 0, partSize)
+	t.add(Part{Length: int64(len(first)), Result: result})
+	if err != nil {
+		result, partResults, err := t.finish()
+		return nil, Download{Result: result, Parts: partResults}, err
+	}
+
+	data := make([]byte, result.ObjectSize)
+	copy(data, first)
+	var mu sync.Mutex
+	t.run(ctx, result.ObjectSize, int64(len(first)), partSize, concurrency, func(ctx context.Context, offset, length int64) (Result, error) {
+		part, result, err := c.GetObjectRange(ctx, bucket, key, offset, length)
+		if err == nil && int64(len(part)) != length {
+			err = fmt.Errorf("part at %d returned %d Bytes, expected %d", offset, len(part), length)
+		}
+		if err == nil {
+			mu.Lock()
+			copy(data[offset:], part)
+			mu.Unlock()
+		}
+		return result, err
+	})
+	result, partResults, err := t.finish()
+	if err != nil {
+		return nil, Download{Result: result, Parts: partResults}, err
+	}
+	return data, Download{Result: result, Parts: partResults}, nil
+}
+
+// transfer collects the parts of a Download or an Upload as they complete.
+type transfer struct {
+	start time.Time
+	mu    sync.Mutex
+	parts []Part
+	// err is the first failure, the parts it cancels fail after it.
+	err error
+}
+
+func newTransfer() *transfer {
+	return &transfer{start: time.Now()}
+}
+
+func (t *transfer) add(part Part) {
+	t.mu.Lock()
+	t.parts = append(t.parts, part)
+	if part.Err != nil && t.err == nil {
+		t.err = part.Err
+	}
+	t.mu.Unlock()
+}
+
+// run moves the bytes from offset up to size in parts of partSize, running
+// at most concurrency of them at a time. The first failure cancels the rest.
+func (t *transfer) run(ctx context.Context, size, offset, partSize int64, concurrency int, move func(ctx context.Context, offset, length int64) (Result, error)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for ; offset < size && ctx.Err() == nil; offset += partSize {
+		length := partSize
+		if size-offset < length {
+			length = size - offset
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(offset, length int64) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Since(t.start).Microseconds()
+			result, err := move(ctx, offset, length)
+			t.add(Part{Offset: offset, Length: length, Start: start, Result: result})
+			if err != nil {
+				log.Debugf("Part %d+%d failed: %v", offset, length, err)
+				cancel()
+			}
+		}(offset, length)
+	}
+	wg.Wait()
+}
+
+// finish sums the parts up as described for Download.
+func (t *transfer) finish() (Result, []Part, error) {
+	sort.Slice(t.parts, func(i, j int) bool { return t.parts[i].Offset < t.parts[j].Offset })
+	total := &Result{}
+	total.E2ETime = time.Since(t.start).Microseconds()
+	lastByte := int64(0)
+	for i, part := range t.parts {
+		if end := part.Start + part.TargetTime; end > total.TargetTime {
+			total.TargetTime = end
+		}
+		if first := part.Start + part.ConnectTime; i == 0 || first < total.ConnectTime {
+			total.ConnectTime = first
+		}
+		if first := part.Start + part.FirstByteTime; part.FirstByteTime > 0 && (total.FirstByteTime == 0 || first < total.FirstByteTime) {
+			total.FirstByteTime = first
+		}
+		if last := part.Start + part.E2ETime - part.DelayTime; last > lastByte {
+			lastByte = last
+		}
+		if part.ObjectSize > total.ObjectSize {
+			total.ObjectSize = part.ObjectSize
+		}
+		total.Bytes += part.Bytes
+		total.Attempts += part.Attempts
+		total.BackoffTime += part.BackoffTime
+		if part.Overshoot > total.Overshoot {
+			total.Overshoot = part.Overshoot
+		}
+		total.MissedTarget = total.MissedTarget || part.MissedTarget
+	}
+	total.Err = t.err
+	total.TimedOut = IsTimeout(t.err)
+	total.TransferTime = lastByte - total.ConnectTime
+	total.DelayTime = total.E2ETime - lastByte
+	return *total, t.parts, total.Err
+}
+
+// ClientParallelGet downloads a synthetic object in parts through the shared
+// client for addr, see Client.ParallelGet.
+func ClientParallelGet(ctx context.Context, size int64, parts int, addr string) (Download, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Download{Result: Result{Err: err}}, err
+	}
+	return c.ParallelGet(ctx, size, parts)
+}
+
+// ClientParallelGetObject downloads bucket/key in parts through the shared
+// client for addr, see Client.ParallelGetObject.
+func ClientParallelGetObject(ctx context.Context, bucket, key string, partSize int64, concurrency int, addr string) ([]byte, Download, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return nil, Download{Result: Result{Err: err}}, err
+	}
+	return c.ParallelGetObject(ctx, bucket, key, partSize, concurrency)
+}
@@ -0,0 +1,252 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+	utils "github.com/JooyoungPark73/mocks3/utils"
+)
+
+// MinPartSize is the smallest part S3, and mocks3, accept for any part of a
+// multipart upload but the last.
+const MinPartSize = 5 * 1024 * 1024
+
+// CompletedPart is a part stored by UploadPart, to be listed when the upload
+// completes.
+type CompletedPart struct {
+	PartNumber int32
+	ETag       string
+	Size       int64
+}
+
+// Upload describes a multipart upload. Result sums the parts up like for a
+// Download. Parts lists the part uploads, preceded by the call that created
+// the upload, at offset -1, and followed by the one that completed it.
+type Upload struct {
+	Result
+	UploadID string
+	Parts    []Part
+}
+
+// CreateMultipartUpload starts a multipart upload of bucket/key, which gets
+// meta once completed, and returns its ID. Like the calls that complete and
+// abort an upload, it is paced as a PUT of zero bytes.
+func (c *Client) CreateMultipartUpload(ctx context.Context, bucket, key string, meta Metadata) (string, Result, error) {
+	req := &pb.NewMultipartUpload{Object: &pb.ObjectRef{Bucket: bucket, Key: key}, Metadata: meta.proto()}
+	var uploadID string
+	result, err := c.retry(ctx, func() (Result, error) {
+		t := startTiming(c.model.GetTimeToSleep("PUT", 0).Microseconds())
+		upload, err := pb.NewFileServiceClient(c.conn()).CreateMultipartUpload(ctx, req, t.callOption())
+		if err != nil {
+			return t.fail(fmt.Errorf("client.CreateMultipartUpload Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		uploadID = upload.GetUploadId()
+		return t.finish(ctx)
+	})
+	return uploadID, result, err
+}
+
+// UploadPart stores data as part partNumber, from 1 to 10000, of an upload.
+// Parts may be uploaded in any order and in parallel, and uploading a part
+// number again replaces it. The target time is derived from the part size.
+func (c *Client) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, data []byte) (CompletedPart, Result, error) {
+	return c.uploadPartRetry(ctx, bucket, key, uploadID, partNumber, int64(len(data)), data)
+}
+
+func (c *Client) uploadPartRetry(ctx context.Context, bucket, key, uploadID string, partNumber int32, size int64, data []byte) (CompletedPart, Result, error) {
+	var part CompletedPart
+	result, err := c.retry(ctx, func() (Result, error) {
+		var (
+			r   Result
+			err error
+		)
+		part, r, err = c.uploadPart(ctx, bucket, key, uploadID, partNumber, size, data)
+		return r, err
+	})
+	return part, result, err
+}
+
+// uploadPart sends data, or size bytes of random data when it is nil.
+func (c *Client) uploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, size int64, data []byte) (CompletedPart, Result, error) {
+	t := startTiming(c.model.GetTimeToSleep("PUT", size).Microseconds())
+
+	// gRPC Connection from the pool
+	fs := pb.NewFileServiceClient(c.conn())
+	stream, err := fs.UploadPart(ctx, t.callOption())
+	if err != nil {
+		r, err := t.fail(fmt.Errorf("client.UploadPart Connection Failed: %w", err))
+		return CompletedPart{}, r, err
+	}
+	t.connected()
+
+	synthetic := data == nil
+	if synthetic {
+		data = utils.CreateRandomObject(2 * 1024 * 1024)
+	}
+	// Send the part, the first chunk names it, then its checksums
+	digest := object.NewDigester(true, c.checksum == pb.ChecksumAlgorithm_SHA256)
+	chunkSize := int64(2 * 1024 * 1024)
+	for offset := int64(0); offset == 0 || offset < size; offset += chunkSize {
+		length := chunkSize
+		if size-offset < length {
+			length = size - offset
+		}
+		blob := data[:length]
+		if !synthetic {
+			blob = data[offset : offset+length]
+		}
+		chunk := &pb.FileBlob{Blob: blob}
+		if offset == 0 {
+			chunk.Upload = &pb.MultipartUpload{Object: &pb.ObjectRef{Bucket: bucket, Key: key}, UploadId: uploadID}
+			chunk.PartNumber = partNumber
+			chunk.Size = size
+			chunk.ChecksumAlgorithm = c.checksum
+		}
+		digest.Write(blob)
+		if err := stream.Send(chunk); err != nil {
+			if err == io.EOF {
+				// The server ended the stream, CloseAndRecv reports why
+				break
+			}
+			r, err := t.fail(fmt.Errorf("client.UploadPart Send Failed: %w", err))
+			return CompletedPart{}, r, err
+		}
+		t.sent(len(blob))
+	}
+	if err := sendChecksums(stream, &pb.FileBlob{}, digest); err != nil {
+		r, err := t.fail(fmt.Errorf("client.UploadPart Send Failed: %w", err))
+		return CompletedPart{}, r, err
+	}
+
+	uploaded, err := stream.CloseAndRecv()
+	if err != nil {
+		r, err := t.fail(fmt.Errorf("client.UploadPart Recv Failed: %w", err))
+		return CompletedPart{}, r, err
+	}
+	t.received(0)
+	t.transferred()
+	log.Debugf("Sent part %d of upload %s, %d Bytes", partNumber, uploadID, uploaded.GetSize())
+
+	r, err := t.finish(ctx)
+	part := CompletedPart{PartNumber: uploaded.GetPartNumber(), ETag: uploaded.GetEtag(), Size: uploaded.GetSize()}
+	return part, r, err
+}
+
+// CompleteMultipartUpload assembles the parts, listed in ascending part
+// number order, into the object. Parts left out are discarded.
+func (c *Client) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) (Result, error) {
+	req := &pb.CompletedMultipartUpload{Upload: &pb.MultipartUpload{Object: &pb.ObjectRef{Bucket: bucket, Key: key}, UploadId: uploadID}}
+	for _, part := range parts {
+		req.Parts = append(req.Parts, &pb.UploadedPart{PartNumber: part.PartNumber, Etag: part.ETag})
+	}
+	return c.retry(ctx, func() (Result, error) {
+		t := startTiming(c.model.GetTimeToSleep("PUT", 0).Microseconds())
+		size, err := pb.NewFileServiceClient(c.conn()).CompleteMultipartUpload(ctx, req, t.callOption())
+		if err != nil {
+			return t.fail(fmt.Errorf("client.CompleteMultipartUpload Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		t.result.ObjectSize = size.GetSize()
+		return t.finish(ctx)
+	})
+}
+
+// AbortMultipartUpload drops an upload and the parts stored so far.
+func (c *Client) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) (Result, error) {
+	req := &pb.MultipartUpload{Object: &pb.ObjectRef{Bucket: bucket, Key: key}, UploadId: uploadID}
+	return c.retry(ctx, func() (Result, error) {
+		t := startTiming(c.model.GetTimeToSleep("PUT", 0).Microseconds())
+		if _, err := pb.NewFileServiceClient(c.conn()).AbortMultipartUpload(ctx, req, t.callOption()); err != nil {
+			return t.fail(fmt.Errorf("client.AbortMultipartUpload Failed: %w", err))
+		}
+		t.received(0)
+		t.transferred()
+		return t.finish(ctx)
+	})
+}
+
+// MultipartPutObject uploads data as bucket/key in parts of partSize bytes,
+// with at most concurrency of them in flight, like the S3 upload manager. A
+// failed upload is aborted.
+func (c *Client) MultipartPutObject(ctx context.Context, bucket, key string, data []byte, partSize int64, concurrency int) (Upload, error) {
+	return c.multipartPut(ctx, bucket, key, int64(len(data)), data, partSize, concurrency)
+}
+
+// MultipartPut is MultipartPutObject for size bytes of random data.
+func (c *Client) MultipartPut(ctx context.Context, bucket, key string, size, partSize int64, concurrency int) (Upload, error) {
+	return c.multipartPut(ctx, bucket, key, size, nil, partSize, concurrency)
+}
+
+func (c *Client) multipartPut(ctx context.Context, bucket, key string, size int64, data []byte, partSize int64, concurrency int) (Upload, error) {
+	if partSize < MinPartSize || concurrency < 1 {
+		return Upload{}, fmt.Errorf("an upload needs parts of at least %d Bytes and a positive concurrency, got %d and %d", MinPartSize, partSize, concurrency)
+	}
+	t := newTransfer()
+	uploadID, result, err := c.CreateMultipartUpload(ctx, bucket, key, Metadata{})
+	t.add(Part{Offset: -1, Result: result})
+	if err != nil {
+		total, parts, err := t.finish()
+		return Upload{Result: total, Parts: parts}, err
+	}
+
+	completed := make([]CompletedPart, (size+partSize-1)/partSize)
+	if size == 0 {
+		// An empty object is still made of one, empty, part.
+		completed = make([]CompletedPart, 1)
+	}
+	t.run(ctx, size, 0, partSize, concurrency, func(ctx context.Context, offset, length int64) (Result, error) {
+		number := int32(offset/partSize) + 1
+		var partData []byte
+		if data != nil {
+			partData = data[offset : offset+length]
+		}
+		part, result, err := c.uploadPartRetry(ctx, bucket, key, uploadID, number, length, partData)
+		completed[number-1] = part
+		return result, err
+	})
+	if size == 0 {
+		part, result, _ := c.uploadPartRetry(ctx, bucket, key, uploadID, 1, 0, []byte{})
+		completed[0] = part
+		t.add(Part{Result: result})
+	}
+	if t.err == nil {
+		result, err = c.CompleteMultipartUpload(ctx, bucket, key, uploadID, completed)
+		t.add(Part{Offset: size, Result: result})
+	}
+	if t.err != nil {
+		// Use a fresh context, the one given may be what failed the upload.
+		if _, err := c.AbortMultipartUpload(context.Background(), bucket, key, uploadID); err != nil {
+			log.Warnf("failed to abort upload %s: %v", uploadID, err)
+		}
+	}
+	total, parts, err := t.finish()
+	return Upload{Result: total, UploadID: uploadID, Parts: parts}, err
+}
+
+// ClientMultipartPutObject uploads data as bucket/key in parts through the
+// shared client for addr, see Client.MultipartPutObject.
+func ClientMultipartPutObject(ctx context.Context, bucket, key string, data []byte, partSize int64, concurrency int, addr string) (Upload, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Upload{Result: Result{Err: err}}, err
+	}
+	return c.MultipartPutObject(ctx, bucket, key, data, partSize, concurrency)
+}
+
+// ClientMultipartPut uploads size bytes of random data as bucket/key in parts
+// through the shared client for addr, see Client.MultipartPut.
+func ClientMultipartPut(ctx context.Context, bucket, key string, size, partSize int64, concurrency int, addr string) (Upload, error) {
+	c, err := sharedClient(addr)
+	if err != nil {
+		return Upload{Result: Result{Err: err}}, err
+	}
+	return c.MultipartPut(ctx, bucket, key, size, partSize, concurrency)
+}
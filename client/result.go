@@ -0,0 +1,157 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Result describes one request. Times are in microseconds since the last
+// attempt started, except for E2ETime which spans every attempt and for
+// TransferTime, DelayTime, Overshoot and BackoffTime which are durations.
+type Result struct {
+	// E2ETime is the time the request took, including the emulated delay.
+	E2ETime int64
+	// TargetTime is the latency the model asked for, zero when the server
+	// paced the request.
+	TargetTime int64
+	// ConnectTime is when the stream was open on a pooled connection.
+	ConnectTime int64
+	// FirstByteTime is when the first byte of the response arrived. The
+	// response to a PUT only arrives once the whole body has been sent.
+	FirstByteTime int64
+	// TransferTime is how long the bytes took to move once the stream was
+	// open.
+	TransferTime int64
+	// Bytes is the payload size moved, which may be short on failure.
+	Bytes int64
+	// ObjectSize is the size of the whole object a GET read from, as
+	// reported by the server.
+	ObjectSize int64
+	// NotModified is set when a conditional GET found the object unchanged
+	// and got no bytes.
+	NotModified bool
+	// DelayTime is how long the client slept to reach TargetTime.
+	DelayTime int64
+	// Overshoot is how far the real request went past TargetTime, in which
+	// case there was nothing left to sleep.
+	Overshoot int64
+	// MissedTarget is set when the real request alone took longer than
+	// TargetTime, so E2ETime does not follow the latency model.
+	MissedTarget bool
+	// ServerPaced is set when the server enforced its latency model on the
+	// request, which the client then does not pad.
+	ServerPaced bool
+	// Attempts counts the tries the request took, retries included.
+	Attempts int
+	// BackoffTime is how long the client waited between attempts.
+	BackoffTime int64
+	// TimedOut is set when the request ran past the deadline of its context.
+	TimedOut bool
+	// Err is the error the request ended with, also returned on its own.
+	Err error
+}
+
+// IsTimeout reports whether err comes from a request that ran past its
+// deadline, as opposed to one that was canceled or failed.
+func IsTimeout(err error) bool {
+	return status.Code(err) == codes.DeadlineExceeded
+}
+
+// timing fills in the Result of a request as it progresses.
+type timing struct {
+	start  time.Time
+	result Result
+	header metadata.MD
+}
+
+// pacedHeader is set by a server that paces the request itself, see the
+// -latency flag of the server.
+const pacedHeader = "mocks3-paced"
+
+// callOption collects the response headers, which tell whether the server
+// paced the request. It must be passed to the RPC of every attempt.
+func (t *timing) callOption() grpc.CallOption {
+	return grpc.Header(&t.header)
+}
+
+func startTiming(targetTime int64) *timing {
+	return &timing{start: time.Now(), result: Result{TargetTime: targetTime}}
+}
+
+func (t *timing) since() int64 {
+	return time.Since(t.start).Microseconds()
+}
+
+func (t *timing) connected() {
+	t.result.ConnectTime = t.since()
+	log.Debugf("GRPC Connection Time: %d us", t.result.ConnectTime)
+}
+
+// received accounts for n bytes of the response.
+func (t *timing) received(n int) {
+	if t.result.FirstByteTime == 0 {
+		t.result.FirstByteTime = t.since()
+	}
+	t.result.Bytes += int64(n)
+}
+
+// sent accounts for n bytes of the request body.
+func (t *timing) sent(n int) {
+	t.result.Bytes += int64(n)
+}
+
+// transferred marks the end of the transfer.
+func (t *timing) transferred() {
+	t.result.TransferTime = t.since() - t.result.ConnectTime
+	log.Debugf("Transferred %d Bytes, commTime: %d us", t.result.Bytes, t.result.TransferTime)
+}
+
+// fail ends the request with err.
+func (t *timing) fail(err error) (Result, error) {
+	t.result.E2ETime = t.since()
+	t.result.TimedOut = IsTimeout(err)
+	t.result.Err = err
+	return t.result, err
+}
+
+// finish sleeps out the rest of the target time unless ctx ends first. A
+// request the server paced already took as long as its model said and is not
+// padded again.
+func (t *timing) finish(ctx context.Context) (Result, error) {
+	if len(t.header.Get(pacedHeader)) > 0 {
+		t.result.ServerPaced = true
+		t.result.TargetTime = 0
+		t.result.E2ETime = t.since()
+		log.Debugf("Paced by the server, no delay")
+		return t.result, nil
+	}
+	remaining := t.result.TargetTime - t.since()
+	if remaining < 0 {
+		t.result.Overshoot = -remaining
+		t.result.MissedTarget = true
+		log.Debugf("Missed target time %d us by %d us", t.result.TargetTime, t.result.Overshoot)
+	}
+	log.Debugf("Time to sleep: %d us, net sleep: %d us", t.result.TargetTime, remaining)
+	if remaining > 0 {
+		slept := time.Now()
+		timer := time.NewTimer(time.Duration(remaining) * time.Microsecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			t.result.DelayTime = time.Since(slept).Microseconds()
+			return t.fail(fmt.Errorf("emulated delay interrupted: %w", status.FromContextError(ctx.Err()).Err()))
+		}
+		t.result.DelayTime = time.Since(slept).Microseconds()
+	}
+	t.result.E2ETime = t.since()
+	return t.result, nil
+}
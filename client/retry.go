@@ -0,0 +1,186 @@
+package mocks3
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	utils "github.com/JooyoungPark73/mocks3/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// JitterModes lists the ways a RetryPolicy can randomize its backoff, as
+// described in the AWS "Exponential Backoff And Jitter" article.
+var JitterModes = []string{"none", "full", "equal", "decorrelated"}
+
+// RetryPolicy decides which failed requests are tried again and how long the
+// client backs off in between. Backoff grows as BaseDelay * 2^(attempt-1),
+// capped at MaxDelay, before jitter is applied.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, so 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter is one of JitterModes.
+	Jitter string
+	// RetryableCodes lists the gRPC status codes worth another attempt.
+	RetryableCodes []codes.Code
+}
+
+// DefaultRetryPolicy mirrors the standard retry mode of the AWS SDKs: three
+// attempts with full jitter on throttling and transient errors.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      100 * time.Millisecond,
+		MaxDelay:       20 * time.Second,
+		Jitter:         "full",
+		RetryableCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Internal},
+	}
+}
+
+// FlagRetryPolicy builds the policy selected by the -retry-* flags.
+func FlagRetryPolicy() (RetryPolicy, error) {
+	p := RetryPolicy{
+		MaxAttempts: *utils.RetryAttempts,
+		BaseDelay:   *utils.RetryBase,
+		MaxDelay:    *utils.RetryCap,
+		Jitter:      *utils.RetryJitter,
+	}
+	for _, name := range strings.Split(*utils.RetryCodes, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		code, err := parseCode(name)
+		if err != nil {
+			return RetryPolicy{}, err
+		}
+		p.RetryableCodes = append(p.RetryableCodes, code)
+	}
+	return p, p.validate()
+}
+
+// parseCode accepts a status code by its Go name, such as Unavailable, or by
+// its canonical name, such as RESOURCE_EXHAUSTED.
+func parseCode(name string) (codes.Code, error) {
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(name) + `"`)); err == nil {
+		return code, nil
+	}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), name) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status code %q", name)
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry policy needs at least 1 attempt, got %d", p.MaxAttempts)
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry delays must not be negative")
+	}
+	for _, mode := range JitterModes {
+		if p.Jitter == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown jitter mode %q - choose from %v", p.Jitter, JitterModes)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the attempt after attempt, given
+// the previous backoff for decorrelated jitter.
+func (p RetryPolicy) backoff(attempt int, previous time.Duration) time.Duration {
+	// BaseDelay doubles with every attempt. Only a shift that would overflow
+	// is capped up front, a zero BaseDelay stays zero.
+	d := p.BaseDelay
+	if shift := attempt - 1; shift > 0 {
+		if shift >= 63 || d > math.MaxInt64>>shift {
+			d = p.MaxDelay
+		} else {
+			d <<= shift
+		}
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	switch p.Jitter {
+	case "full":
+		return upTo(d)
+	case "equal":
+		return d/2 + upTo(d/2)
+	case "decorrelated":
+		if previous < p.BaseDelay {
+			previous = p.BaseDelay
+		}
+		// Past a third of the largest duration, 3*previous would overflow.
+		span := time.Duration(math.MaxInt64)
+		if previous <= math.MaxInt64/3 {
+			span = 3 * previous
+		}
+		d = p.BaseDelay + upTo(span-p.BaseDelay)
+		if d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+	}
+	return d
+}
+
+// upTo returns a random duration within [0, d]. rand.Int63n(d+1) would
+// overflow for the largest duration.
+func upTo(d time.Duration) time.Duration {
+	if d == math.MaxInt64 {
+		return time.Duration(rand.Int63())
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retry runs attempt until it succeeds, fails for good or the policy gives
+// up. The Result is the one of the last attempt, except that E2ETime spans
+// every attempt and the backoffs between them.
+func (c *Client) retry(ctx context.Context, attempt func() (Result, error)) (Result, error) {
+	start := time.Now()
+	var backoff, backoffTime time.Duration
+	for n := 1; ; n++ {
+		result, err := attempt()
+		result.Attempts = n
+		result.BackoffTime = backoffTime.Microseconds()
+		if err == nil || n >= c.retryPolicy.MaxAttempts || !c.retryPolicy.retryable(err) || ctx.Err() != nil {
+			result.E2ETime = time.Since(start).Microseconds()
+			return result, err
+		}
+
+		backoff = c.retryPolicy.backoff(n, backoff)
+		log.Debugf("Attempt %d failed, retrying in %d us: %v", n, backoff.Microseconds(), err)
+		slept := time.Now()
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			t := &timing{start: start, result: result}
+			t.result.BackoffTime = (backoffTime + time.Since(slept)).Microseconds()
+			return t.fail(fmt.Errorf("retry backoff interrupted: %w", status.FromContextError(ctx.Err()).Err()))
+		}
+		backoffTime += time.Since(slept)
+	}
+}
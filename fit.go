@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+)
+
+// runFit implements "mocks3 fit": it fits a curve to each benchmark CSV and
+// writes a latency model, keeping the built-in curve for operations that
+// were not measured.
+func runFit(args []string) {
+	fitFlags := flag.NewFlagSet("fit", flag.ExitOnError)
+	getCSV := fitFlags.String("get", "", "GET benchmark CSV, e.g. get_benchmark.csv")
+	putCSV := fitFlags.String("put", "", "PUT benchmark CSV, e.g. put_benchmark.csv")
+	family := fitFlags.String("family", "explog", "Curve family - choose from [explog, linear, sqrt, constant]")
+	out := fitFlags.String("out", "latency_model.json", "Where to write the fitted latency model")
+	fitFlags.Parse(args)
+
+	if *getCSV == "" && *putCSV == "" {
+		log.Error("fit needs at least one of -get or -put")
+		fitFlags.Usage()
+		os.Exit(2)
+	}
+
+	model := latency.DefaultModel()
+	for _, input := range []struct{ commType, path string }{{"GET", *getCSV}, {"PUT", *putCSV}} {
+		if input.path == "" {
+			log.Infof("%s: keeping the built-in curve", input.commType)
+			continue
+		}
+		sizes, latencies, err := latency.ReadBenchmark(input.path)
+		if err != nil {
+			log.Fatalf("could not read %s: %v", input.path, err)
+		}
+		curve, rmse, err := latency.Fit(sizes, latencies, *family)
+		if err != nil {
+			log.Fatalf("could not fit %s: %v", input.path, err)
+		}
+		log.Infof("%s: %.8g * exp(%.8g * log10(x)) + %.8g us, RMSE %.0f us over %d samples",
+			input.commType, curve.A, curve.B, curve.C, rmse, len(sizes))
+		// The built-in HEAD, LIST, DELETE, BATCH_DELETE and COPY curves are
+		// derived from GET and PUT, drop them to fall back on the fitted ones.
+		model.Replace(input.commType, curve)
+	}
+
+	if err := model.Save(*out); err != nil {
+		log.Fatalf("could not write %s: %v", *out, err)
+	}
+	log.Infof("latency model written to %s", *out)
+}
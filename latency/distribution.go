@@ -0,0 +1,143 @@
+package mocks3
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Distribution describes how latencies spread around the fitted curve. Each
+// draw is a multiplier of the curve value.
+//
+//	{"type": "lognormal", "sigma": 0.4}
+//	{"type": "gamma", "shape": 4}
+//	{"type": "empirical", "histogram": [{"low": 0.8, "high": 1.2, "weight": 95}, {"low": 3, "high": 8, "weight": 5}]}
+//	{"type": "mixture", "base": {"type": "lognormal", "sigma": 0.2}, "tail": {"type": "gamma", "shape": 2}, "tail_percent": 1, "tail_scale": 5}
+type Distribution struct {
+	// Type is one of deterministic, lognormal, gamma, empirical or mixture.
+	Type string `json:"type"`
+	// Sigma is the standard deviation of the log of a lognormal draw.
+	Sigma float64 `json:"sigma,omitempty"`
+	// Shape is the gamma shape parameter, lower values give heavier tails.
+	Shape float64 `json:"shape,omitempty"`
+	// Histogram lists the multiplier ranges of an empirical distribution.
+	Histogram []Bin `json:"histogram,omitempty"`
+	// A mixture draws from Tail with probability TailPercent/100 and
+	// multiplies that draw by TailScale, otherwise it draws from Base. A
+	// missing Base or Tail is deterministic.
+	Base        *Distribution `json:"base,omitempty"`
+	Tail        *Distribution `json:"tail,omitempty"`
+	TailPercent float64       `json:"tail_percent,omitempty"`
+	TailScale   float64       `json:"tail_scale,omitempty"`
+}
+
+// Bin is one range of multipliers, picked in proportion to its weight and
+// sampled uniformly within [Low, High).
+type Bin struct {
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Weight float64 `json:"weight"`
+}
+
+func (d *Distribution) validate() error {
+	if d == nil {
+		return nil
+	}
+	switch d.Type {
+	case "", "deterministic":
+	case "lognormal":
+		if d.Sigma < 0 {
+			return fmt.Errorf("lognormal sigma must not be negative")
+		}
+	case "gamma":
+		if d.Shape <= 0 {
+			return fmt.Errorf("gamma shape must be positive")
+		}
+	case "empirical":
+		total := 0.0
+		for _, bin := range d.Histogram {
+			if bin.Weight < 0 || bin.Low < 0 || bin.High < bin.Low {
+				return fmt.Errorf("empirical bin [%g, %g) with weight %g is invalid", bin.Low, bin.High, bin.Weight)
+			}
+			total += bin.Weight
+		}
+		if total <= 0 {
+			return fmt.Errorf("empirical histogram needs a positive total weight")
+		}
+	case "mixture":
+		if d.TailPercent < 0 || d.TailPercent > 100 {
+			return fmt.Errorf("mixture tail_percent must be within [0, 100]")
+		}
+		if d.TailScale < 0 {
+			return fmt.Errorf("mixture tail_scale must not be negative")
+		}
+		if err := d.Base.validate(); err != nil {
+			return err
+		}
+		return d.Tail.validate()
+	default:
+		return fmt.Errorf("unknown distribution %q - choose from [deterministic, lognormal, gamma, empirical, mixture]", d.Type)
+	}
+	return nil
+}
+
+// draw returns one multiplier. Lognormal and gamma draws have a mean of 1 so
+// the curve stays the mean latency.
+func (d *Distribution) draw(rng *rand.Rand) float64 {
+	if d == nil {
+		return 1
+	}
+	switch d.Type {
+	case "lognormal":
+		return math.Exp(d.Sigma*rng.NormFloat64() - d.Sigma*d.Sigma/2)
+	case "gamma":
+		return gammaVariate(rng, d.Shape) / d.Shape
+	case "empirical":
+		total := 0.0
+		for _, bin := range d.Histogram {
+			total += bin.Weight
+		}
+		pick := rng.Float64() * total
+		for _, bin := range d.Histogram {
+			if pick < bin.Weight {
+				return bin.Low + rng.Float64()*(bin.High-bin.Low)
+			}
+			pick -= bin.Weight
+		}
+		last := d.Histogram[len(d.Histogram)-1]
+		return last.Low + rng.Float64()*(last.High-last.Low)
+	case "mixture":
+		if rng.Float64()*100 < d.TailPercent {
+			scale := d.TailScale
+			if scale == 0 {
+				scale = 1
+			}
+			return scale * d.Tail.draw(rng)
+		}
+		return d.Base.draw(rng)
+	default:
+		return 1
+	}
+}
+
+// gammaVariate draws from Gamma(shape, 1) with the Marsaglia-Tsang method.
+func gammaVariate(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		// Boost the shape above 1 and scale the draw back down.
+		return gammaVariate(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package mocks3
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Families lists the curve families Fit supports. Each one is the model curve
+// with a constraint on b: explog leaves it free, linear fixes the exponent of
+// x to 1, sqrt to 1/2 and constant drops the size term altogether.
+var Families = []string{"explog", "linear", "sqrt", "constant"}
+
+// ReadBenchmark returns the payload sizes and E2E times of a CSV in the
+// format written by the client benchmarks.
+func ReadBenchmark(path string) (sizes, latencies []float64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	records, err := readColumns(f, sizeColumn, latencyColumn)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, record := range records {
+		sizes = append(sizes, record[0])
+		latencies = append(latencies, record[1])
+	}
+	return sizes, latencies, nil
+}
+
+// Fit finds the curve of family that best matches the latencies, in
+// microseconds, observed at sizes in the least squares sense. It also returns
+// the root mean squared error of the fit.
+func Fit(sizes, latencies []float64, family string) (Curve, float64, error) {
+	if len(sizes) != len(latencies) {
+		return Curve{}, 0, fmt.Errorf("got %d sizes but %d latencies", len(sizes), len(latencies))
+	}
+	if len(sizes) < 2 {
+		return Curve{}, 0, fmt.Errorf("need at least 2 samples, got %d", len(sizes))
+	}
+
+	switch family {
+	case "explog":
+		return fitExponent(sizes, latencies)
+	case "linear":
+		return fitLinear(sizes, latencies, math.Ln10)
+	case "sqrt":
+		return fitLinear(sizes, latencies, math.Ln10/2)
+	case "constant":
+		mean := 0.0
+		for _, y := range latencies {
+			mean += y
+		}
+		curve := Curve{C: mean / float64(len(latencies)), Scale: 1}
+		return curve, rmse(curve, sizes, latencies), nil
+	default:
+		return Curve{}, 0, fmt.Errorf("unknown curve family %q - choose from %v", family, Families)
+	}
+}
+
+// fitExponent searches b on a grid and refines the best cell with a golden
+// section search, solving a and c in closed form for every candidate.
+func fitExponent(sizes, latencies []float64) (Curve, float64, error) {
+	const steps = 400
+	low, high := 1e-3, 3*math.Ln10
+	sse := func(b float64) float64 {
+		_, e, err := fitLinear(sizes, latencies, b)
+		if err != nil {
+			return math.Inf(1)
+		}
+		return e * e
+	}
+
+	best, bestErr := low, math.Inf(1)
+	step := (high - low) / steps
+	for i := 0; i <= steps; i++ {
+		b := low + float64(i)*step
+		if e := sse(b); e < bestErr {
+			best, bestErr = b, e
+		}
+	}
+
+	ratio := (math.Sqrt(5) - 1) / 2
+	lo, hi := math.Max(low, best-step), math.Min(high, best+step)
+	for i := 0; i < 60; i++ {
+		m1 := hi - ratio*(hi-lo)
+		m2 := lo + ratio*(hi-lo)
+		if sse(m1) < sse(m2) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	return fitLinear(sizes, latencies, (lo+hi)/2)
+}
+
+// fitLinear solves a and c for a fixed b. The curve is linear in
+// g(x) = exp(b * log10(x)), so this is an ordinary least squares line fit.
+func fitLinear(sizes, latencies []float64, b float64) (Curve, float64, error) {
+	n := float64(len(sizes))
+	var sumG, sumY, sumGG, sumGY float64
+	for i, x := range sizes {
+		g := Curve{A: 1, B: b, Scale: 1}.Eval(int64(x))
+		sumG += g
+		sumY += latencies[i]
+		sumGG += g * g
+		sumGY += g * latencies[i]
+	}
+	// det is never negative, and only rounding keeps it from 0 when every
+	// size is the same.
+	det := n*sumGG - sumG*sumG
+	if det <= 1e-12*n*sumGG || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Curve{}, 0, fmt.Errorf("sizes do not vary enough to fit b=%g", b)
+	}
+	a := (n*sumGY - sumG*sumY) / det
+	c := (sumY - a*sumG) / n
+	curve := Curve{A: a, B: b, C: c, Scale: 1}
+	return curve, rmse(curve, sizes, latencies), nil
+}
+
+func rmse(curve Curve, sizes, latencies []float64) float64 {
+	sum := 0.0
+	for i, x := range sizes {
+		d := curve.Eval(int64(x)) - latencies[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(sizes)))
+}
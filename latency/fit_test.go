@@ -0,0 +1,124 @@
+package mocks3
+
+import (
+	"math"
+	"testing"
+)
+
+// samples evaluates curve at sizes from 1 KiB to 1 GiB.
+func samples(curve Curve) (sizes, latencies []float64) {
+	for size := int64(1 << 10); size <= 1<<30; size *= 4 {
+		sizes = append(sizes, float64(size))
+		latencies = append(latencies, curve.Eval(size))
+	}
+	return sizes, latencies
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*math.Max(1, math.Abs(want))
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		family string
+		curve  Curve
+	}{
+		{family: "linear", curve: Curve{A: 0.002, B: math.Ln10, C: 1500, Scale: 1}},
+		{family: "sqrt", curve: Curve{A: 3, B: math.Ln10 / 2, C: 20000, Scale: 1}},
+		{family: "constant", curve: Curve{C: 42000, Scale: 1}},
+		{family: "explog", curve: Curve{A: 120.18868, B: 1.11999534, C: 111248.20149, Scale: 1}},
+		{family: "explog", curve: Curve{A: 0.5, B: 2, C: 5000, Scale: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.family, func(t *testing.T) {
+			sizes, latencies := samples(test.curve)
+			curve, rmse, err := Fit(sizes, latencies, test.family)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(curve.A, test.curve.A, 1e-4) || !near(curve.B, test.curve.B, 1e-4) ||
+				!near(curve.C, test.curve.C, 1e-4) || curve.Scale != 1 {
+				t.Errorf("Fit = %+v, want %+v", curve, test.curve)
+			}
+			if rmse > 1e-3*latencies[0] {
+				t.Errorf("RMSE of an exact fit is %g", rmse)
+			}
+		})
+	}
+}
+
+// TestFitConstant checks that the constant family is the mean latency and
+// that the error of an inexact fit is reported.
+func TestFitConstant(t *testing.T) {
+	curve, rmse, err := Fit([]float64{1, 1000}, []float64{400, 600}, "constant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if curve.A != 0 || curve.C != 500 || rmse != 100 {
+		t.Errorf("Fit = %+v with RMSE %g, want c 500 with RMSE 100", curve, rmse)
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		sizes     []float64
+		latencies []float64
+		family    string
+	}{
+		{name: "length mismatch", sizes: []float64{1, 2, 3}, latencies: []float64{1, 2}, family: "linear"},
+		{name: "one sample", sizes: []float64{1}, latencies: []float64{1}, family: "linear"},
+		{name: "unknown family", sizes: []float64{1, 2}, latencies: []float64{1, 2}, family: "cubic"},
+		{name: "single size", sizes: []float64{100, 100, 100}, latencies: []float64{1, 2, 3}, family: "linear"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := Fit(test.sizes, test.latencies, test.family); err == nil {
+				t.Error("Fit succeeded, want an error")
+			}
+		})
+	}
+}
+
+// TestReplace checks that replacing a curve drops the curves that fall back
+// on it and keeps the others.
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		op   string
+		kept []string
+	}{
+		{op: "GET", kept: []string{"GET", "PUT", "COPY"}},
+		{op: "PUT", kept: []string{"GET", "PUT", "HEAD", "LIST", "DELETE", "BATCH_DELETE"}},
+		{op: "LIST", kept: []string{"GET", "PUT", "HEAD", "LIST", "DELETE", "COPY"}},
+	}
+	for _, test := range tests {
+		t.Run(test.op, func(t *testing.T) {
+			model := DefaultModel()
+			curve := Curve{C: 1, Scale: 1}
+			model.Replace(test.op, curve)
+			if len(model.Operations) != len(test.kept) {
+				t.Errorf("operations %v, want %v", model.Operations, test.kept)
+			}
+			for _, op := range test.kept {
+				if _, ok := model.Operations[op]; !ok {
+					t.Errorf("%s was dropped", op)
+				}
+			}
+			if model.Operations[test.op] != curve {
+				t.Errorf("%s = %+v, want %+v", test.op, model.Operations[test.op], curve)
+			}
+		})
+	}
+}
+
+// TestFitConstantAtZero checks that a constant curve holds at 0 bytes, the
+// size HEAD and DELETE requests are evaluated at.
+func TestFitConstantAtZero(t *testing.T) {
+	curve, _, err := Fit([]float64{1, 1000}, []float64{400, 600}, "constant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := curve.Eval(0); got != 500 {
+		t.Errorf("Eval(0) = %g, want 500", got)
+	}
+}
@@ -0,0 +1,269 @@
+// Package mocks3 holds the latency model shared by the client and the server.
+//
+// A model maps each operation to a curve giving the latency in microseconds
+// of a transfer of x bytes:
+//
+//	scale * (a * exp(b * log10(x)) + c)
+//
+// Models are JSON files such as
+//
+//	{
+//	  "operations": {
+//	    "GET": {"a": 120.18868, "b": 1.11999534, "c": 111248.20149, "scale": 0.33},
+//	    "PUT": {"a": 120.18868, "b": 1.11999534, "c": 111248.20149, "scale": 0.67}
+//	  }
+//	}
+//
+// HEAD and DELETE requests move no payload, so only the curve at zero bytes,
+// scale * c, matters to them. The LIST and BATCH_DELETE curves are evaluated
+// at the number of entries a listing returns and of keys a batch deletes
+// rather than at a size in bytes, and the COPY curve at the size of the
+// object copied. Models must have GET and PUT curves, those without one of
+// the others fall back on another: DELETE on HEAD, BATCH_DELETE on LIST, HEAD
+// and LIST on GET and COPY on PUT.
+//
+// By default the curve is the latency. An operation may add a distribution
+// to sample latencies around the curve instead, and the model may fix a seed
+// to make those samples reproducible. An operation may also replay a trace,
+// a CSV in the format of the client benchmarks, in which case each latency is
+// drawn from the recorded latencies of similarly sized payloads.
+package mocks3
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ModelEnv names the environment variable read when no model path is given.
+const ModelEnv = "MOCKS3_LATENCY_MODEL"
+
+type Curve struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	C float64 `json:"c"`
+	// Scale multiplies the curve and defaults to 1 when omitted.
+	Scale float64 `json:"scale,omitempty"`
+	// Distribution samples around the curve, which is deterministic when
+	// omitted.
+	Distribution *Distribution `json:"distribution,omitempty"`
+	// Trace replaces the curve and distribution by samples from a recorded
+	// CSV, relative to the model file. Sizes are binned by their log10 into
+	// BinsPerDecade bins, 4 when omitted.
+	Trace         string `json:"trace,omitempty"`
+	BinsPerDecade int    `json:"bins_per_decade,omitempty"`
+
+	replay *trace
+}
+
+type Model struct {
+	Operations map[string]Curve `json:"operations"`
+	// Seed makes sampling reproducible. Zero seeds from the clock.
+	Seed int64 `json:"seed,omitempty"`
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// DefaultModel returns the built-in curves.
+func DefaultModel() *Model {
+	// Sourced from: https://github.com/vhive-serverless/MockS3/blob/main/mocks3/mock_io_functions.py
+	// [  0.12018868   1.11999534 111.24820149]
+	// a * np.exp(b * np.log10(x_point)) + c
+	fit := Curve{A: 120.18868, B: 1.11999534, C: 111248.20149}
+	get, put := fit, fit
+	get.Scale = 0.33
+	put.Scale = 0.67
+	// A HEAD costs about the time to first byte of a GET.
+	head := get
+	head.A = 0
+	// A LIST starts like a HEAD and adds about 30 ms for a full page of
+	// 1000 keys.
+	list := Curve{A: 1000, B: 1.5, C: fit.C, Scale: get.Scale}
+	// Deleting 1000 keys takes about 60 ms more than deleting one, and a
+	// server side copy costs about as much as uploading the copy.
+	batchDelete := list
+	batchDelete.Scale = put.Scale
+	return &Model{Operations: map[string]Curve{
+		"GET":          get,
+		"PUT":          put,
+		"HEAD":         head,
+		"LIST":         list,
+		"DELETE":       head,
+		"BATCH_DELETE": batchDelete,
+		"COPY":         put,
+	}}
+}
+
+// fallbacks names the curve used by an operation a model leaves out.
+var fallbacks = map[string]string{
+	"HEAD":         "GET",
+	"LIST":         "GET",
+	"DELETE":       "HEAD",
+	"BATCH_DELETE": "LIST",
+	"COPY":         "PUT",
+}
+
+// Replace sets the curve of op and drops the curves of the operations that
+// fall back on it, directly or not, so they follow the new curve rather than
+// keep one derived from the old.
+func (m *Model) Replace(op string, curve Curve) {
+	m.Operations[op] = curve
+	for dependent, fallback := range fallbacks {
+		for ; fallback != ""; fallback = fallbacks[fallback] {
+			if fallback == op {
+				delete(m.Operations, dependent)
+				break
+			}
+		}
+	}
+}
+
+// Load reads a model file.
+func Load(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, filepath.Dir(path))
+}
+
+// Parse decodes a JSON model. Traces are resolved against the working
+// directory.
+func Parse(data []byte) (*Model, error) {
+	return parse(data, ".")
+}
+
+func parse(data []byte, dir string) (*Model, error) {
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid latency model: %v", err)
+	}
+	// Every operation falls back on GET or PUT, so with those two each one
+	// has a curve.
+	for op := range m.Operations {
+		if op != "GET" && op != "PUT" && fallbacks[op] == "" {
+			return nil, fmt.Errorf("invalid latency model: unknown operation %q - choose from [GET, PUT, HEAD, LIST, DELETE, BATCH_DELETE, COPY]", op)
+		}
+	}
+	for _, op := range []string{"GET", "PUT"} {
+		if _, ok := m.Operations[op]; !ok {
+			return nil, fmt.Errorf("invalid latency model: no %s curve", op)
+		}
+	}
+	for op, curve := range m.Operations {
+		if curve.Scale == 0 {
+			curve.Scale = 1
+			m.Operations[op] = curve
+		}
+		if err := curve.Distribution.validate(); err != nil {
+			return nil, fmt.Errorf("invalid latency model: %s: %v", op, err)
+		}
+		if curve.Trace != "" {
+			path := curve.Trace
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			var err error
+			if curve.replay, err = loadTrace(path, curve.BinsPerDecade); err != nil {
+				return nil, fmt.Errorf("invalid latency model: %s: trace %s: %v", op, curve.Trace, err)
+			}
+			m.Operations[op] = curve
+		}
+	}
+	return &m, nil
+}
+
+// Resolve loads the model at path, falling back to $MOCKS3_LATENCY_MODEL and
+// then to the built-in default.
+func Resolve(path string) (*Model, error) {
+	if path == "" {
+		path = os.Getenv(ModelEnv)
+	}
+	if path == "" {
+		return DefaultModel(), nil
+	}
+	return Load(path)
+}
+
+// Save writes the model as indented JSON.
+func (m *Model) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Eval returns the curve in microseconds at fileSize bytes. The size term is
+// 0 at 0 bytes, where the log is -Inf and a constant curve, with a and b 0,
+// would otherwise give NaN.
+func (c Curve) Eval(fileSize int64) float64 {
+	size := 0.0
+	if c.A != 0 && fileSize > 0 {
+		size = c.A * math.Exp(c.B*math.Log10(float64(fileSize)))
+	}
+	return c.Scale * (size + c.C)
+}
+
+// Sample is one draw from the model for a single transfer. Evaluating the
+// same sample at growing sizes gives growing latencies, so a transfer can be
+// paced against it.
+type Sample struct {
+	curve  Curve
+	factor float64
+	// quantile picks the recorded latency when the curve replays a trace.
+	quantile float64
+}
+
+// Sample draws the latency of one commType transfer. An operation the model
+// has no curve for gets the zero Sample, which is no latency at all.
+func (m *Model) Sample(commType string) Sample {
+	curve, ok := m.Operations[commType]
+	for op := commType; !ok && fallbacks[op] != ""; {
+		op = fallbacks[op]
+		curve, ok = m.Operations[op]
+	}
+	if !ok {
+		log.Errorf("no latency curve for %s, not delaying it", commType)
+		return Sample{}
+	}
+	if curve.Distribution == nil && curve.replay == nil {
+		return Sample{curve: curve, factor: 1}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rng == nil {
+		seed := m.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		m.rng = rand.New(rand.NewSource(seed))
+	}
+	if curve.replay != nil {
+		return Sample{curve: curve, factor: 1, quantile: m.rng.Float64()}
+	}
+	return Sample{curve: curve, factor: curve.Distribution.draw(m.rng)}
+}
+
+// At returns the sampled latency of a transfer of fileSize bytes.
+func (s Sample) At(fileSize int64) time.Duration {
+	if s.factor == 0 {
+		return 0
+	}
+	if s.curve.replay != nil {
+		return time.Duration(s.curve.Scale*s.curve.replay.at(fileSize, s.quantile)) * time.Microsecond
+	}
+	return time.Duration(s.factor*s.curve.Eval(fileSize)) * time.Microsecond
+}
+
+func (m *Model) GetTimeToSleep(commType string, fileSize int64) time.Duration {
+	return m.Sample(commType).At(fileSize)
+}
@@ -0,0 +1,88 @@
+package mocks3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		err   string
+	}{
+		{
+			name:  "get and put",
+			model: `{"operations": {"GET": {"c": 1000}, "PUT": {"c": 2000}}}`,
+		},
+		{
+			name:  "every operation",
+			model: `{"operations": {"GET": {"c": 1}, "PUT": {"c": 1}, "HEAD": {"c": 1}, "LIST": {"c": 1}, "DELETE": {"c": 1}, "BATCH_DELETE": {"c": 1}, "COPY": {"c": 1}}}`,
+		},
+		{
+			name:  "no put",
+			model: `{"operations": {"GET": {"c": 1000}, "HEAD": {"c": 500}}}`,
+			err:   "no PUT curve",
+		},
+		{
+			name:  "no get",
+			model: `{"operations": {"PUT": {"c": 1000}}}`,
+			err:   "no GET curve",
+		},
+		{
+			name:  "misspelled operation",
+			model: `{"operations": {"get": {"c": 1000}, "PUT": {"c": 2000}}}`,
+			err:   `unknown operation "get"`,
+		},
+		{
+			name:  "no operations",
+			model: `{"operations": {}}`,
+			err:   "no GET curve",
+		},
+		{
+			name:  "not JSON",
+			model: `operations`,
+			err:   "invalid latency model",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.model))
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("Parse error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Parse error = %v, want one about %s", err, test.err)
+			}
+		})
+	}
+}
+
+// TestSampleFallbacks checks that every operation gets a curve from a model
+// with only GET and PUT.
+func TestSampleFallbacks(t *testing.T) {
+	m, err := Parse([]byte(`{"operations": {"GET": {"c": 1000}, "PUT": {"c": 2000}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for op, want := range map[string]int64{
+		"GET": 1000, "HEAD": 1000, "LIST": 1000, "DELETE": 1000, "BATCH_DELETE": 1000,
+		"PUT": 2000, "COPY": 2000,
+	} {
+		if got := m.Sample(op).At(0).Microseconds(); got != want {
+			t.Errorf("%s latency = %d us, want %d us", op, got, want)
+		}
+	}
+}
+
+// TestSampleUnknown checks that an operation without a curve is not delayed
+// rather than crashing the caller.
+func TestSampleUnknown(t *testing.T) {
+	m := &Model{Operations: map[string]Curve{"GET": {C: 1000, Scale: 1}}}
+	if got := m.Sample("PUT").At(1000); got != 0 {
+		t.Errorf("latency without a curve = %v, want 0", got)
+	}
+}
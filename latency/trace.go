@@ -0,0 +1,127 @@
+package mocks3
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const (
+	sizeColumn    = "Payload Size (Bytes)"
+	latencyColumn = "E2E Time (us)"
+	errorColumn   = "Error"
+
+	defaultBinsPerDecade = 4
+)
+
+// trace holds recorded latencies binned by the log10 of the payload size.
+type trace struct {
+	binsPerDecade int
+	// bins maps a bin index to its latencies in microseconds, sorted.
+	bins    map[int][]float64
+	indexes []int
+}
+
+// loadTrace reads a CSV in the format written by the client benchmarks.
+func loadTrace(path string, binsPerDecade int) (*trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readTrace(f, binsPerDecade)
+}
+
+func readTrace(r io.Reader, binsPerDecade int) (*trace, error) {
+	if binsPerDecade <= 0 {
+		binsPerDecade = defaultBinsPerDecade
+	}
+	records, err := readColumns(r, sizeColumn, latencyColumn)
+	if err != nil {
+		return nil, err
+	}
+	t := &trace{binsPerDecade: binsPerDecade, bins: make(map[int][]float64)}
+	for _, record := range records {
+		i := t.bin(int64(record[0]))
+		t.bins[i] = append(t.bins[i], record[1])
+	}
+	if len(t.bins) == 0 {
+		return nil, fmt.Errorf("trace has no samples")
+	}
+	for i, latencies := range t.bins {
+		sort.Float64s(latencies)
+		t.indexes = append(t.indexes, i)
+	}
+	sort.Ints(t.indexes)
+	return t, nil
+}
+
+// readColumns returns the named columns of every row of a CSV with a header.
+// Rows of failed requests, which have a non-empty Error column, are skipped.
+func readColumns(r io.Reader, names ...string) ([][]float64, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV is empty")
+	}
+	columns := make([]int, len(names))
+	for i, name := range names {
+		columns[i] = -1
+		for j, header := range rows[0] {
+			if header == name {
+				columns[i] = j
+			}
+		}
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("CSV has no %q column", name)
+		}
+	}
+	errors := -1
+	for j, header := range rows[0] {
+		if header == errorColumn {
+			errors = j
+		}
+	}
+	var records [][]float64
+	for line, row := range rows[1:] {
+		if errors >= 0 && row[errors] != "" {
+			continue
+		}
+		record := make([]float64, len(columns))
+		for i, column := range columns {
+			if record[i], err = strconv.ParseFloat(row[column], 64); err != nil {
+				return nil, fmt.Errorf("CSV line %d: %v", line+2, err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (t *trace) bin(size int64) int {
+	if size < 1 {
+		size = 1
+	}
+	return int(math.Floor(math.Log10(float64(size)) * float64(t.binsPerDecade)))
+}
+
+// at returns the latency at quantile q of the bin holding size, falling back
+// to the closest bin that has samples.
+func (t *trace) at(size int64, q float64) float64 {
+	want := t.bin(size)
+	i := sort.SearchInts(t.indexes, want)
+	switch {
+	case i == len(t.indexes):
+		i--
+	case t.indexes[i] != want && i > 0 && want-t.indexes[i-1] < t.indexes[i]-want:
+		i--
+	}
+	latencies := t.bins[t.indexes[i]]
+	return latencies[int(q*float64(len(latencies)))]
+}
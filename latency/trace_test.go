@@ -0,0 +1,119 @@
+package mocks3
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTraceBin(t *testing.T) {
+	tests := []struct {
+		size          int64
+		binsPerDecade int
+		want          int
+	}{
+		{size: 0, binsPerDecade: 4, want: 0},
+		{size: 1, binsPerDecade: 4, want: 0},
+		{size: 9, binsPerDecade: 4, want: 3},
+		{size: 10, binsPerDecade: 4, want: 4},
+		{size: 1000, binsPerDecade: 4, want: 12},
+		// 10^3.25 is 1778.3.
+		{size: 1778, binsPerDecade: 4, want: 12},
+		{size: 1779, binsPerDecade: 4, want: 13},
+		{size: 1 << 30, binsPerDecade: 4, want: 36},
+		{size: 999, binsPerDecade: 1, want: 2},
+		{size: 1000, binsPerDecade: 1, want: 3},
+		{size: 1000, binsPerDecade: 10, want: 30},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d at %d per decade", test.size, test.binsPerDecade), func(t *testing.T) {
+			tr := &trace{binsPerDecade: test.binsPerDecade}
+			if got := tr.bin(test.size); got != test.want {
+				t.Errorf("bin(%d) = %d, want %d", test.size, got, test.want)
+			}
+		})
+	}
+}
+
+const testTrace = `Payload Size (Bytes),E2E Time (us),Error
+100,30,
+100,10,
+120,20,
+100,999,deadline exceeded
+10000,500,
+`
+
+func TestReadTrace(t *testing.T) {
+	tests := []struct {
+		binsPerDecade int
+		bins          map[int][]float64
+	}{
+		// Failed requests are left out and 0 means the default of 4.
+		{binsPerDecade: 0, bins: map[int][]float64{8: {10, 20, 30}, 16: {500}}},
+		{binsPerDecade: 1, bins: map[int][]float64{2: {10, 20, 30}, 4: {500}}},
+		{binsPerDecade: 10, bins: map[int][]float64{20: {10, 20, 30}, 40: {500}}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.binsPerDecade), func(t *testing.T) {
+			tr, err := readTrace(strings.NewReader(testTrace), test.binsPerDecade)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tr.bins, test.bins) {
+				t.Errorf("bins = %v, want %v", tr.bins, test.bins)
+			}
+		})
+	}
+}
+
+func TestTraceAt(t *testing.T) {
+	tr, err := readTrace(strings.NewReader(testTrace), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		size     int64
+		quantile float64
+		want     float64
+	}{
+		{size: 100, quantile: 0, want: 10},
+		{size: 100, quantile: 0.5, want: 20},
+		{size: 100, quantile: 0.99, want: 30},
+		{size: 110, quantile: 0, want: 10},
+		{size: 10000, quantile: 0.5, want: 500},
+		// Empty bins fall back on the closest bin with samples, the larger
+		// one on a tie.
+		{size: 1, quantile: 0, want: 10},
+		{size: 900, quantile: 0, want: 10},
+		{size: 1000, quantile: 0, want: 500},
+		{size: 1 << 30, quantile: 0, want: 500},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d at %g", test.size, test.quantile), func(t *testing.T) {
+			if got := tr.at(test.size, test.quantile); got != test.want {
+				t.Errorf("at(%d, %g) = %g, want %g", test.size, test.quantile, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadTraceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{name: "empty", csv: ""},
+		{name: "no size column", csv: "E2E Time (us)\n10\n"},
+		{name: "no latency column", csv: "Payload Size (Bytes)\n10\n"},
+		{name: "not a number", csv: "Payload Size (Bytes),E2E Time (us)\n10,fast\n"},
+		{name: "only failures", csv: "Payload Size (Bytes),E2E Time (us),Error\n10,20,unavailable\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readTrace(strings.NewReader(test.csv), 4); err == nil {
+				t.Error("readTrace succeeded, want an error")
+			}
+		})
+	}
+}
@@ -12,6 +12,10 @@ func init() {
 }
 
 func main() {
+	if flag.Arg(0) == "fit" {
+		runFit(flag.Args()[1:])
+		return
+	}
 	mocks3_client.BenchmarkClientPut(*mocks3_utils.TestIteration)
 	mocks3_client.BenchmarkClientGet(*mocks3_utils.TestIteration)
 }
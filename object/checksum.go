@@ -0,0 +1,98 @@
+// Package mocks3 holds the object checksums and request preconditions shared
+// by the client and the server, with their conversions to and from the
+// protocol messages.
+package mocks3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+)
+
+// ErrBadDigest tells that bytes do not match the checksums they came with.
+var ErrBadDigest = errors.New("the bytes received do not match their checksum")
+
+// Checksums are those of the bytes of an object. ETag is the hex MD5, or
+// for an object assembled from parts the MD5 of the part MD5s followed by
+// -<number of parts>. CRC32C and SHA256 are base64 like the S3 headers.
+type Checksums struct {
+	ETag   string `json:"etag,omitempty"`
+	CRC32C string `json:"crc32c,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+func ChecksumsFrom(sums *pb.Checksums) Checksums {
+	return Checksums{ETag: sums.GetEtag(), CRC32C: sums.GetCrc32C(), SHA256: sums.GetSha256()}
+}
+
+func (c Checksums) Proto() *pb.Checksums {
+	return &pb.Checksums{Etag: c.ETag, Crc32C: c.CRC32C, Sha256: c.SHA256}
+}
+
+// Verify checks c against the checksums a client computed. Those it left
+// empty are not checked.
+func (c Checksums) Verify(expected Checksums) error {
+	for _, sum := range []struct{ name, got, want string }{
+		{"ETag", c.ETag, expected.ETag},
+		{"CRC32C", c.CRC32C, expected.CRC32C},
+		{"SHA256", c.SHA256, expected.SHA256},
+	} {
+		if sum.want != "" && sum.got != sum.want {
+			return fmt.Errorf("%w: %s is %s, expected %s", ErrBadDigest, sum.name, sum.got, sum.want)
+		}
+	}
+	return nil
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Digester computes the checksums of the bytes written to it. The CRC32C is
+// always computed, the MD5 and the SHA-256 only on request since they cost
+// far more.
+type Digester struct {
+	crc32c hash.Hash32
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+func NewDigester(withMD5, withSHA256 bool) *Digester {
+	d := &Digester{crc32c: crc32.New(castagnoli)}
+	if withMD5 {
+		d.md5 = md5.New()
+	}
+	if withSHA256 {
+		d.sha256 = sha256.New()
+	}
+	return d
+}
+
+func (d *Digester) Write(p []byte) (int, error) {
+	d.crc32c.Write(p)
+	if d.md5 != nil {
+		d.md5.Write(p)
+	}
+	if d.sha256 != nil {
+		d.sha256.Write(p)
+	}
+	return len(p), nil
+}
+
+// Sum returns the checksums of the bytes written so far. Those not computed
+// are empty.
+func (d *Digester) Sum() Checksums {
+	sums := Checksums{CRC32C: base64.StdEncoding.EncodeToString(d.crc32c.Sum(nil))}
+	if d.md5 != nil {
+		sums.ETag = hex.EncodeToString(d.md5.Sum(nil))
+	}
+	if d.sha256 != nil {
+		sums.SHA256 = base64.StdEncoding.EncodeToString(d.sha256.Sum(nil))
+	}
+	return sums
+}
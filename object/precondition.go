@@ -0,0 +1,46 @@
+package mocks3
+
+import (
+	"time"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Preconditions make a request depend on the stored object, like the S3
+// If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since
+// headers. IfMatch and IfNoneMatch list ETags separated by commas, or are *
+// to match any object. Zero fields are not evaluated.
+type Preconditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+func PreconditionsFrom(p *pb.Preconditions) Preconditions {
+	cond := Preconditions{IfMatch: p.GetIfMatch(), IfNoneMatch: p.GetIfNoneMatch()}
+	if p.GetIfModifiedSince() != nil {
+		cond.IfModifiedSince = p.GetIfModifiedSince().AsTime()
+	}
+	if p.GetIfUnmodifiedSince() != nil {
+		cond.IfUnmodifiedSince = p.GetIfUnmodifiedSince().AsTime()
+	}
+	return cond
+}
+
+func (p Preconditions) Proto() *pb.Preconditions {
+	cond := &pb.Preconditions{IfMatch: p.IfMatch, IfNoneMatch: p.IfNoneMatch}
+	if !p.IfModifiedSince.IsZero() {
+		cond.IfModifiedSince = timestamppb.New(p.IfModifiedSince)
+	}
+	if !p.IfUnmodifiedSince.IsZero() {
+		cond.IfUnmodifiedSince = timestamppb.New(p.IfUnmodifiedSince)
+	}
+	return cond
+}
+
+func (p Preconditions) IsZero() bool {
+	return p.IfMatch == "" && p.IfNoneMatch == "" && p.IfModifiedSince.IsZero() && p.IfUnmodifiedSince.IsZero()
+}
@@ -0,0 +1,436 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v4.25.1
+// source: proto/admin_service.proto
+
+package mocks3
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	GetLatencyModel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LatencyModel, error)
+	// SetLatencyModel rejects models without GET and PUT curves. It fails
+	// when the server paces nothing, with -latency client and no S3 API.
+	SetLatencyModel(ctx context.Context, in *LatencyModel, opts ...grpc.CallOption) (*LatencyModel, error)
+	GetFaultRules(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*FaultRules, error)
+	SetFaultRules(ctx context.Context, in *FaultRules, opts ...grpc.CallOption) (*FaultRules, error)
+	GetBandwidth(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Bandwidth, error)
+	SetBandwidth(ctx context.Context, in *Bandwidth, opts ...grpc.CallOption) (*Bandwidth, error)
+	GetLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LogLevel, error)
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error)
+	GetCounters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Counters, error)
+	// ResetCounters returns the counters as they were before the reset.
+	ResetCounters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Counters, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) GetLatencyModel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LatencyModel, error) {
+	out := new(LatencyModel)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/GetLatencyModel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetLatencyModel(ctx context.Context, in *LatencyModel, opts ...grpc.CallOption) (*LatencyModel, error) {
+	out := new(LatencyModel)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/SetLatencyModel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetFaultRules(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*FaultRules, error) {
+	out := new(FaultRules)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/GetFaultRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetFaultRules(ctx context.Context, in *FaultRules, opts ...grpc.CallOption) (*FaultRules, error) {
+	out := new(FaultRules)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/SetFaultRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetBandwidth(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Bandwidth, error) {
+	out := new(Bandwidth)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/GetBandwidth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetBandwidth(ctx context.Context, in *Bandwidth, opts ...grpc.CallOption) (*Bandwidth, error) {
+	out := new(Bandwidth)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/SetBandwidth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/GetLogLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/SetLogLevel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetCounters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Counters, error) {
+	out := new(Counters)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/GetCounters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ResetCounters(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Counters, error) {
+	out := new(Counters)
+	err := c.cc.Invoke(ctx, "/proto.AdminService/ResetCounters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	GetLatencyModel(context.Context, *emptypb.Empty) (*LatencyModel, error)
+	// SetLatencyModel rejects models without GET and PUT curves. It fails
+	// when the server paces nothing, with -latency client and no S3 API.
+	SetLatencyModel(context.Context, *LatencyModel) (*LatencyModel, error)
+	GetFaultRules(context.Context, *emptypb.Empty) (*FaultRules, error)
+	SetFaultRules(context.Context, *FaultRules) (*FaultRules, error)
+	GetBandwidth(context.Context, *emptypb.Empty) (*Bandwidth, error)
+	SetBandwidth(context.Context, *Bandwidth) (*Bandwidth, error)
+	GetLogLevel(context.Context, *emptypb.Empty) (*LogLevel, error)
+	SetLogLevel(context.Context, *LogLevel) (*LogLevel, error)
+	GetCounters(context.Context, *emptypb.Empty) (*Counters, error)
+	// ResetCounters returns the counters as they were before the reset.
+	ResetCounters(context.Context, *emptypb.Empty) (*Counters, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) GetLatencyModel(context.Context, *emptypb.Empty) (*LatencyModel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatencyModel not implemented")
+}
+func (UnimplementedAdminServiceServer) SetLatencyModel(context.Context, *LatencyModel) (*LatencyModel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLatencyModel not implemented")
+}
+func (UnimplementedAdminServiceServer) GetFaultRules(context.Context, *emptypb.Empty) (*FaultRules, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFaultRules not implemented")
+}
+func (UnimplementedAdminServiceServer) SetFaultRules(context.Context, *FaultRules) (*FaultRules, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFaultRules not implemented")
+}
+func (UnimplementedAdminServiceServer) GetBandwidth(context.Context, *emptypb.Empty) (*Bandwidth, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBandwidth not implemented")
+}
+func (UnimplementedAdminServiceServer) SetBandwidth(context.Context, *Bandwidth) (*Bandwidth, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetBandwidth not implemented")
+}
+func (UnimplementedAdminServiceServer) GetLogLevel(context.Context, *emptypb.Empty) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) SetLogLevel(context.Context, *LogLevel) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) GetCounters(context.Context, *emptypb.Empty) (*Counters, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCounters not implemented")
+}
+func (UnimplementedAdminServiceServer) ResetCounters(context.Context, *emptypb.Empty) (*Counters, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetCounters not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_GetLatencyModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetLatencyModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/GetLatencyModel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetLatencyModel(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetLatencyModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LatencyModel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetLatencyModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/SetLatencyModel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetLatencyModel(ctx, req.(*LatencyModel))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetFaultRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetFaultRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/GetFaultRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetFaultRules(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetFaultRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FaultRules)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetFaultRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/SetFaultRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetFaultRules(ctx, req.(*FaultRules))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetBandwidth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetBandwidth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/GetBandwidth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetBandwidth(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetBandwidth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Bandwidth)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetBandwidth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/SetBandwidth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetBandwidth(ctx, req.(*Bandwidth))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/GetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, req.(*LogLevel))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetCounters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetCounters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/GetCounters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetCounters(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ResetCounters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ResetCounters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.AdminService/ResetCounters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ResetCounters(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLatencyModel",
+			Handler:    _AdminService_GetLatencyModel_Handler,
+		},
+		{
+			MethodName: "SetLatencyModel",
+			Handler:    _AdminService_SetLatencyModel_Handler,
+		},
+		{
+			MethodName: "GetFaultRules",
+			Handler:    _AdminService_GetFaultRules_Handler,
+		},
+		{
+			MethodName: "SetFaultRules",
+			Handler:    _AdminService_SetFaultRules_Handler,
+		},
+		{
+			MethodName: "GetBandwidth",
+			Handler:    _AdminService_GetBandwidth_Handler,
+		},
+		{
+			MethodName: "SetBandwidth",
+			Handler:    _AdminService_SetBandwidth_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _AdminService_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _AdminService_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "GetCounters",
+			Handler:    _AdminService_GetCounters_Handler,
+		},
+		{
+			MethodName: "ResetCounters",
+			Handler:    _AdminService_ResetCounters_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/admin_service.proto",
+}
@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -24,6 +25,24 @@ const _ = grpc.SupportPackageIsVersion7
 type FileServiceClient interface {
 	GetFile(ctx context.Context, in *FileSize, opts ...grpc.CallOption) (FileService_GetFileClient, error)
 	PutFile(ctx context.Context, opts ...grpc.CallOption) (FileService_PutFileClient, error)
+	// HeadFile describes a stored object without reading it.
+	HeadFile(ctx context.Context, in *ObjectRef, opts ...grpc.CallOption) (*ObjectMetadata, error)
+	// ListFiles lists the objects of a bucket like S3 ListObjectsV2.
+	ListFiles(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResult, error)
+	// DeleteFile removes an object. Like S3 it succeeds when there is none.
+	DeleteFile(ctx context.Context, in *ObjectRef, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// DeleteFiles removes up to 1000 objects of a bucket and reports on
+	// each of them.
+	DeleteFiles(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResult, error)
+	// CopyFile duplicates an object within the server and describes the
+	// copy.
+	CopyFile(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ObjectMetadata, error)
+	// Multipart uploads store an object as parts uploaded independently,
+	// in any order and in parallel, then assembled by part number.
+	CreateMultipartUpload(ctx context.Context, in *NewMultipartUpload, opts ...grpc.CallOption) (*MultipartUpload, error)
+	UploadPart(ctx context.Context, opts ...grpc.CallOption) (FileService_UploadPartClient, error)
+	CompleteMultipartUpload(ctx context.Context, in *CompletedMultipartUpload, opts ...grpc.CallOption) (*FileSize, error)
+	AbortMultipartUpload(ctx context.Context, in *MultipartUpload, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type fileServiceClient struct {
@@ -100,12 +119,136 @@ func (x *fileServicePutFileClient) CloseAndRecv() (*FileSize, error) {
 	return m, nil
 }
 
+func (c *fileServiceClient) HeadFile(ctx context.Context, in *ObjectRef, opts ...grpc.CallOption) (*ObjectMetadata, error) {
+	out := new(ObjectMetadata)
+	err := c.cc.Invoke(ctx, "/proto.FileService/HeadFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) ListFiles(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResult, error) {
+	out := new(ListResult)
+	err := c.cc.Invoke(ctx, "/proto.FileService/ListFiles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) DeleteFile(ctx context.Context, in *ObjectRef, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/proto.FileService/DeleteFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) DeleteFiles(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResult, error) {
+	out := new(DeleteResult)
+	err := c.cc.Invoke(ctx, "/proto.FileService/DeleteFiles", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) CopyFile(ctx context.Context, in *CopyRequest, opts ...grpc.CallOption) (*ObjectMetadata, error) {
+	out := new(ObjectMetadata)
+	err := c.cc.Invoke(ctx, "/proto.FileService/CopyFile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) CreateMultipartUpload(ctx context.Context, in *NewMultipartUpload, opts ...grpc.CallOption) (*MultipartUpload, error) {
+	out := new(MultipartUpload)
+	err := c.cc.Invoke(ctx, "/proto.FileService/CreateMultipartUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) UploadPart(ctx context.Context, opts ...grpc.CallOption) (FileService_UploadPartClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FileService_ServiceDesc.Streams[2], "/proto.FileService/UploadPart", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fileServiceUploadPartClient{stream}
+	return x, nil
+}
+
+type FileService_UploadPartClient interface {
+	Send(*FileBlob) error
+	CloseAndRecv() (*UploadedPart, error)
+	grpc.ClientStream
+}
+
+type fileServiceUploadPartClient struct {
+	grpc.ClientStream
+}
+
+func (x *fileServiceUploadPartClient) Send(m *FileBlob) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *fileServiceUploadPartClient) CloseAndRecv() (*UploadedPart, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadedPart)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *fileServiceClient) CompleteMultipartUpload(ctx context.Context, in *CompletedMultipartUpload, opts ...grpc.CallOption) (*FileSize, error) {
+	out := new(FileSize)
+	err := c.cc.Invoke(ctx, "/proto.FileService/CompleteMultipartUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileServiceClient) AbortMultipartUpload(ctx context.Context, in *MultipartUpload, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/proto.FileService/AbortMultipartUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileServiceServer is the server API for FileService service.
 // All implementations must embed UnimplementedFileServiceServer
 // for forward compatibility
 type FileServiceServer interface {
 	GetFile(*FileSize, FileService_GetFileServer) error
 	PutFile(FileService_PutFileServer) error
+	// HeadFile describes a stored object without reading it.
+	HeadFile(context.Context, *ObjectRef) (*ObjectMetadata, error)
+	// ListFiles lists the objects of a bucket like S3 ListObjectsV2.
+	ListFiles(context.Context, *ListRequest) (*ListResult, error)
+	// DeleteFile removes an object. Like S3 it succeeds when there is none.
+	DeleteFile(context.Context, *ObjectRef) (*emptypb.Empty, error)
+	// DeleteFiles removes up to 1000 objects of a bucket and reports on
+	// each of them.
+	DeleteFiles(context.Context, *DeleteRequest) (*DeleteResult, error)
+	// CopyFile duplicates an object within the server and describes the
+	// copy.
+	CopyFile(context.Context, *CopyRequest) (*ObjectMetadata, error)
+	// Multipart uploads store an object as parts uploaded independently,
+	// in any order and in parallel, then assembled by part number.
+	CreateMultipartUpload(context.Context, *NewMultipartUpload) (*MultipartUpload, error)
+	UploadPart(FileService_UploadPartServer) error
+	CompleteMultipartUpload(context.Context, *CompletedMultipartUpload) (*FileSize, error)
+	AbortMultipartUpload(context.Context, *MultipartUpload) (*emptypb.Empty, error)
 	mustEmbedUnimplementedFileServiceServer()
 }
 
@@ -119,6 +262,33 @@ func (UnimplementedFileServiceServer) GetFile(*FileSize, FileService_GetFileServ
 func (UnimplementedFileServiceServer) PutFile(FileService_PutFileServer) error {
 	return status.Errorf(codes.Unimplemented, "method PutFile not implemented")
 }
+func (UnimplementedFileServiceServer) HeadFile(context.Context, *ObjectRef) (*ObjectMetadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HeadFile not implemented")
+}
+func (UnimplementedFileServiceServer) ListFiles(context.Context, *ListRequest) (*ListResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedFileServiceServer) DeleteFile(context.Context, *ObjectRef) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
+}
+func (UnimplementedFileServiceServer) DeleteFiles(context.Context, *DeleteRequest) (*DeleteResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFiles not implemented")
+}
+func (UnimplementedFileServiceServer) CopyFile(context.Context, *CopyRequest) (*ObjectMetadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CopyFile not implemented")
+}
+func (UnimplementedFileServiceServer) CreateMultipartUpload(context.Context, *NewMultipartUpload) (*MultipartUpload, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateMultipartUpload not implemented")
+}
+func (UnimplementedFileServiceServer) UploadPart(FileService_UploadPartServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadPart not implemented")
+}
+func (UnimplementedFileServiceServer) CompleteMultipartUpload(context.Context, *CompletedMultipartUpload) (*FileSize, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteMultipartUpload not implemented")
+}
+func (UnimplementedFileServiceServer) AbortMultipartUpload(context.Context, *MultipartUpload) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortMultipartUpload not implemented")
+}
 func (UnimplementedFileServiceServer) mustEmbedUnimplementedFileServiceServer() {}
 
 // UnsafeFileServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -179,13 +349,216 @@ func (x *fileServicePutFileServer) Recv() (*FileBlob, error) {
 	return m, nil
 }
 
+func _FileService_HeadFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ObjectRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).HeadFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/HeadFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).HeadFile(ctx, req.(*ObjectRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/ListFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).ListFiles(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ObjectRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/DeleteFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).DeleteFile(ctx, req.(*ObjectRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_DeleteFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).DeleteFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/DeleteFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).DeleteFiles(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_CopyFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).CopyFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/CopyFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).CopyFile(ctx, req.(*CopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_CreateMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewMultipartUpload)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).CreateMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/CreateMultipartUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).CreateMultipartUpload(ctx, req.(*NewMultipartUpload))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_UploadPart_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileServiceServer).UploadPart(&fileServiceUploadPartServer{stream})
+}
+
+type FileService_UploadPartServer interface {
+	SendAndClose(*UploadedPart) error
+	Recv() (*FileBlob, error)
+	grpc.ServerStream
+}
+
+type fileServiceUploadPartServer struct {
+	grpc.ServerStream
+}
+
+func (x *fileServiceUploadPartServer) SendAndClose(m *UploadedPart) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *fileServiceUploadPartServer) Recv() (*FileBlob, error) {
+	m := new(FileBlob)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _FileService_CompleteMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompletedMultipartUpload)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).CompleteMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/CompleteMultipartUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).CompleteMultipartUpload(ctx, req.(*CompletedMultipartUpload))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileService_AbortMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultipartUpload)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileServiceServer).AbortMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.FileService/AbortMultipartUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileServiceServer).AbortMultipartUpload(ctx, req.(*MultipartUpload))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FileService_ServiceDesc is the grpc.ServiceDesc for FileService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var FileService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.FileService",
 	HandlerType: (*FileServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "HeadFile",
+			Handler:    _FileService_HeadFile_Handler,
+		},
+		{
+			MethodName: "ListFiles",
+			Handler:    _FileService_ListFiles_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _FileService_DeleteFile_Handler,
+		},
+		{
+			MethodName: "DeleteFiles",
+			Handler:    _FileService_DeleteFiles_Handler,
+		},
+		{
+			MethodName: "CopyFile",
+			Handler:    _FileService_CopyFile_Handler,
+		},
+		{
+			MethodName: "CreateMultipartUpload",
+			Handler:    _FileService_CreateMultipartUpload_Handler,
+		},
+		{
+			MethodName: "CompleteMultipartUpload",
+			Handler:    _FileService_CompleteMultipartUpload_Handler,
+		},
+		{
+			MethodName: "AbortMultipartUpload",
+			Handler:    _FileService_AbortMultipartUpload_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "GetFile",
@@ -197,6 +570,11 @@ var FileService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _FileService_PutFile_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "UploadPart",
+			Handler:       _FileService_UploadPart_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/file_service.proto",
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"math/rand"
 	"os"
@@ -17,6 +18,10 @@ var (
 	numberOfWorker      int
 	numberOfCPM         int
 	imageSize           int
+	poolSize            int
+	requestTimeout      time.Duration
+	retryAttempts       int
+	downloadParts       int
 	verbosity           = flag.Lookup("verbosity").Value.(flag.Getter).Get().(string)
 )
 
@@ -48,6 +53,30 @@ func getEnvironmentVariables() {
 		imageSize = 128
 	}
 	log.Infof("IMAGE_SIZE = %d MB", imageSize)
+
+	if _, ok := os.LookupEnv("CONNECTION_POOL_SIZE"); ok {
+		poolSize, _ = strconv.Atoi(os.Getenv("CONNECTION_POOL_SIZE"))
+	} else {
+		poolSize = 4
+	}
+	log.Infof("CONNECTION_POOL_SIZE = %d", poolSize)
+
+	if _, ok := os.LookupEnv("REQUEST_TIMEOUT"); ok {
+		requestTimeout, _ = time.ParseDuration(os.Getenv("REQUEST_TIMEOUT"))
+	}
+	log.Infof("REQUEST_TIMEOUT = %s", requestTimeout)
+
+	if _, ok := os.LookupEnv("DOWNLOAD_PARTS"); ok {
+		downloadParts, _ = strconv.Atoi(os.Getenv("DOWNLOAD_PARTS"))
+	} else {
+		downloadParts = 1
+	}
+	log.Infof("DOWNLOAD_PARTS = %d", downloadParts)
+
+	if _, ok := os.LookupEnv("RETRY_MAX_ATTEMPTS"); ok {
+		retryAttempts, _ = strconv.Atoi(os.Getenv("RETRY_MAX_ATTEMPTS"))
+		log.Infof("RETRY_MAX_ATTEMPTS = %d", retryAttempts)
+	}
 }
 
 func init() {
@@ -71,8 +100,7 @@ func init() {
 	}
 }
 
-func pullImage(cpmPerWorker int) {
-	var get_e2e_time int64
+func pullImage(client *mocks3_client.Client, cpmPerWorker int) {
 	var waitTime time.Duration
 	// to avoid all coldstart at the same time
 	initialWaitTime := (rand.Float32()*30 + 10)
@@ -81,12 +109,36 @@ func pullImage(cpmPerWorker int) {
 
 	for {
 		start := time.Now()
-		get_e2e_time, _ = mocks3_client.ClientGet(int64(imageSize*1024*1024), mockS3ServerAddress)
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if requestTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		}
+		var result mocks3_client.Result
+		var err error
+		if downloadParts > 1 {
+			var download mocks3_client.Download
+			download, err = client.ParallelGet(ctx, int64(imageSize*1024*1024), downloadParts)
+			result = download.Result
+		} else {
+			result, err = client.Get(ctx, int64(imageSize*1024*1024))
+		}
+		cancel()
 
 		waitTime = time.Duration(rand.ExpFloat64()*(60/float64(cpmPerWorker))) * time.Second
 
 		timeToSleep := waitTime - time.Since(start)
-		log.Infof("Wait: %.2f s, GET: %.2f, net Wait: %.2f s", waitTime.Seconds(), float64(get_e2e_time)/1000000, timeToSleep.Seconds())
+		if result.TimedOut {
+			log.Warnf("Wait: %.2f s, GET timed out after %.2f s, net Wait: %.2f s", waitTime.Seconds(), float64(result.E2ETime)/1000000, timeToSleep.Seconds())
+		} else if err != nil {
+			log.Warnf("Wait: %.2f s, GET failed after %d attempts: %v, net Wait: %.2f s", waitTime.Seconds(), result.Attempts, err, timeToSleep.Seconds())
+		} else {
+			log.Infof("Wait: %.2f s, GET: %.2f in %d attempts (connect %.3f, first byte %.3f, transfer %.2f, delay %.2f, overshoot %.2f) s, net Wait: %.2f s",
+				waitTime.Seconds(), float64(result.E2ETime)/1000000, result.Attempts, float64(result.ConnectTime)/1000000, float64(result.FirstByteTime)/1000000,
+				float64(result.TransferTime)/1000000, float64(result.DelayTime)/1000000, float64(result.Overshoot)/1000000, timeToSleep.Seconds())
+			if result.MissedTarget {
+				log.Warnf("GET missed its target time %.2f s by %.2f s", float64(result.TargetTime)/1000000, float64(result.Overshoot)/1000000)
+			}
+		}
 
 		time.Sleep(timeToSleep)
 	}
@@ -96,12 +148,25 @@ func main() {
 	cpmPerWorker := numberOfCPM / numberOfWorker
 	log.Infof("CPM per worker: %d", cpmPerWorker)
 
+	opts := []mocks3_client.Option{mocks3_client.WithAddress(mockS3ServerAddress), mocks3_client.WithPoolSize(poolSize)}
+	if retryAttempts > 0 {
+		// The standard AWS SDK policy with the requested number of attempts
+		policy := mocks3_client.DefaultRetryPolicy()
+		policy.MaxAttempts = retryAttempts
+		opts = append(opts, mocks3_client.WithRetryPolicy(policy))
+	}
+	client, err := mocks3_client.NewClient(opts...)
+	if err != nil {
+		log.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
 	var wg sync.WaitGroup
 	for i := 0; i < numberOfWorker; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			pullImage(cpmPerWorker)
+			pullImage(client, cpmPerWorker)
 		}()
 	}
 
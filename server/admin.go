@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// adminServer changes the behavior of a running server. Changes apply to
+// requests that start after them.
+type adminServer struct {
+	pb.UnimplementedAdminServiceServer
+
+	server *server
+}
+
+func (a *adminServer) GetLatencyModel(ctx context.Context, _ *emptypb.Empty) (*pb.LatencyModel, error) {
+	data, err := json.Marshal(a.server.model.Load())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not encode latency model: %v", err)
+	}
+	return &pb.LatencyModel{Json: string(data)}, nil
+}
+
+// SetLatencyModel replaces the model the server paces requests with. Models
+// are validated before they are swapped in, one without a GET or a PUT curve
+// or with an unknown operation is rejected with InvalidArgument. The server
+// paces the S3 API and, with -latency server, the FileService. With the
+// default -latency client and no S3 API the model would not be used, so the
+// call fails with FailedPrecondition.
+func (a *adminServer) SetLatencyModel(ctx context.Context, req *pb.LatencyModel) (*pb.LatencyModel, error) {
+	if *latencyMode != "server" && *httpPort == "" {
+		return nil, status.Error(codes.FailedPrecondition, "the server paces no request with -latency client and no -http-port, clients keep their own latency model")
+	}
+	model, err := latency.Parse([]byte(req.GetJson()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	a.server.model.Store(model)
+	log.Infof("admin: latency model replaced")
+	return a.GetLatencyModel(ctx, nil)
+}
+
+func (a *adminServer) GetFaultRules(ctx context.Context, _ *emptypb.Empty) (*pb.FaultRules, error) {
+	rules := a.server.faults.get()
+	if rules == nil {
+		rules = []faultRule{}
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not encode fault rules: %v", err)
+	}
+	return &pb.FaultRules{Json: string(data)}, nil
+}
+
+func (a *adminServer) SetFaultRules(ctx context.Context, req *pb.FaultRules) (*pb.FaultRules, error) {
+	var rules []faultRule
+	if err := json.Unmarshal([]byte(req.GetJson()), &rules); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid fault rules: %v", err)
+	}
+	if err := a.server.faults.set(rules); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Infof("admin: %d fault rules set", len(rules))
+	return a.GetFaultRules(ctx, nil)
+}
+
+func (a *adminServer) GetBandwidth(ctx context.Context, _ *emptypb.Empty) (*pb.Bandwidth, error) {
+	streamRate, clientRate, globalRate := a.server.bandwidth.rates()
+	return &pb.Bandwidth{Stream: streamRate / mib, Client: clientRate / mib, Global: globalRate / mib}, nil
+}
+
+func (a *adminServer) SetBandwidth(ctx context.Context, req *pb.Bandwidth) (*pb.Bandwidth, error) {
+	if req.GetStream() < 0 || req.GetClient() < 0 || req.GetGlobal() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "bandwidth caps must not be negative")
+	}
+	a.server.bandwidth.setRates(req.GetStream()*mib, req.GetClient()*mib, req.GetGlobal()*mib)
+	log.Infof("admin: bandwidth set to stream %.1f, client %.1f, global %.1f MiB/s", req.GetStream(), req.GetClient(), req.GetGlobal())
+	return a.GetBandwidth(ctx, nil)
+}
+
+func (a *adminServer) GetLogLevel(ctx context.Context, _ *emptypb.Empty) (*pb.LogLevel, error) {
+	return &pb.LogLevel{Level: log.GetLevel().String()}, nil
+}
+
+func (a *adminServer) SetLogLevel(ctx context.Context, req *pb.LogLevel) (*pb.LogLevel, error) {
+	level, err := log.ParseLevel(req.GetLevel())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.SetLevel(level)
+	log.Infof("admin: log level set to %s", level)
+	return a.GetLogLevel(ctx, nil)
+}
+
+func (a *adminServer) GetCounters(ctx context.Context, _ *emptypb.Empty) (*pb.Counters, error) {
+	return a.server.counters.snapshot(), nil
+}
+
+func (a *adminServer) ResetCounters(ctx context.Context, _ *emptypb.Empty) (*pb.Counters, error) {
+	return a.server.counters.reset(), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetLatencyModel(t *testing.T) {
+	defer func(mode, port string) { *latencyMode, *httpPort = mode, port }(*latencyMode, *httpPort)
+	tests := []struct {
+		name      string
+		mode      string
+		httpPort  string
+		model     string
+		code      codes.Code
+		replacing bool
+	}{
+		{
+			name:      "server mode",
+			mode:      "server",
+			model:     `{"operations": {"GET": {"c": 1}, "PUT": {"c": 2}}}`,
+			code:      codes.OK,
+			replacing: true,
+		},
+		{
+			name:      "client mode with the S3 API",
+			mode:      "client",
+			httpPort:  "9000",
+			model:     `{"operations": {"GET": {"c": 1}, "PUT": {"c": 2}}}`,
+			code:      codes.OK,
+			replacing: true,
+		},
+		{
+			name:  "client mode only",
+			mode:  "client",
+			model: `{"operations": {"GET": {"c": 1}, "PUT": {"c": 2}}}`,
+			code:  codes.FailedPrecondition,
+		},
+		{
+			name:  "no put",
+			mode:  "server",
+			model: `{"operations": {"GET": {"c": 1}}}`,
+			code:  codes.InvalidArgument,
+		},
+		{
+			name:  "unknown operation",
+			mode:  "server",
+			model: `{"operations": {"GET": {"c": 1}, "PUT": {"c": 2}, "POST": {"c": 3}}}`,
+			code:  codes.InvalidArgument,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*latencyMode, *httpPort = test.mode, test.httpPort
+			initial := latency.DefaultModel()
+			a := &adminServer{server: newServer(newMemoryStore(), initial, newBandwidth(0, 0, 0))}
+			_, err := a.SetLatencyModel(context.Background(), &pb.LatencyModel{Json: test.model})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("SetLatencyModel code = %v (%v), want %v", code, err, test.code)
+			}
+			if replaced := a.server.model.Load() != initial; replaced != test.replacing {
+				t.Errorf("model replaced = %t, want %t", replaced, test.replacing)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/peer"
+)
+
+const mib = 1024 * 1024
+
+// tokenBucket limits a byte rate. Callers reserve bytes up front, so the
+// bucket may go into debt and concurrent streams are served in turn. A zero
+// rate means unlimited.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(bytesPerSecond float64) *tokenBucket {
+	return &tokenBucket{rate: bytesPerSecond, last: time.Now()}
+}
+
+func (b *tokenBucket) setRate(bytesPerSecond float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = bytesPerSecond
+	b.tokens = 0
+	b.last = time.Now()
+}
+
+// reserve takes n bytes and returns how long the caller must wait before
+// moving them.
+func (b *tokenBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	// Allow bursts of up to 100ms worth of bytes after an idle period.
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if burst := b.rate / 10; b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// bandwidth caps the throughput of each stream, of each client and of the
+// server as a whole. Stream caps apply to streams opened after a change.
+type bandwidth struct {
+	mu         sync.Mutex
+	streamRate float64
+	clientRate float64
+	global     *tokenBucket
+	clients    map[string]*clientBucket
+}
+
+type clientBucket struct {
+	*tokenBucket
+	streams int
+}
+
+// newBandwidth takes caps in bytes per second, zero meaning unlimited.
+func newBandwidth(streamRate, clientRate, globalRate float64) *bandwidth {
+	return &bandwidth{
+		streamRate: streamRate,
+		clientRate: clientRate,
+		global:     newTokenBucket(globalRate),
+		clients:    make(map[string]*clientBucket),
+	}
+}
+
+func (b *bandwidth) setRates(streamRate, clientRate, globalRate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.streamRate = streamRate
+	b.clientRate = clientRate
+	for _, bucket := range b.clients {
+		bucket.setRate(clientRate)
+	}
+	b.global.setRate(globalRate)
+}
+
+func (b *bandwidth) rates() (streamRate, clientRate, globalRate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.global.mu.Lock()
+	defer b.global.mu.Unlock()
+	return b.streamRate, b.clientRate, b.global.rate
+}
+
+// stream starts limiting one transfer for client. The limiter must be closed
+// once the transfer is over.
+func (b *bandwidth) stream(client string) *limiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket, ok := b.clients[client]
+	if !ok {
+		bucket = &clientBucket{tokenBucket: newTokenBucket(b.clientRate)}
+		b.clients[client] = bucket
+	}
+	bucket.streams++
+	return &limiter{
+		buckets: []*tokenBucket{newTokenBucket(b.streamRate), bucket.tokenBucket, b.global},
+		release: func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if bucket.streams--; bucket.streams == 0 {
+				delete(b.clients, client)
+			}
+		},
+	}
+}
+
+// limiter throttles one transfer against all the buckets it belongs to.
+type limiter struct {
+	buckets []*tokenBucket
+	release func()
+}
+
+// wait blocks until n bytes may move without exceeding any cap. The bytes are
+// taken from one bucket at a time, from the stream to the whole server, once
+// the previous caps let them through: reserving them everywhere up front
+// would charge the shared buckets, and hold back other streams, for bytes
+// still waiting on a narrower cap.
+func (l *limiter) wait(n int) {
+	for _, bucket := range l.buckets {
+		time.Sleep(bucket.reserve(n))
+	}
+}
+
+func (l *limiter) close() {
+	l.release()
+}
+
+// peerHost names the client of a gRPC stream by its host.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return remoteHost(p.Addr.String())
+}
+
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// throttledReader limits the bytes read from an upload body.
+type throttledReader struct {
+	io.Reader
+	limit *limiter
+}
+
+func (r *throttledReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.limit.wait(n)
+	return n, err
+}
+
+// throttledWriter limits the bytes written to a download body.
+type throttledWriter struct {
+	io.Writer
+	limit *limiter
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	w.limit.wait(len(b))
+	return w.Writer.Write(b)
+}
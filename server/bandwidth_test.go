@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLimiterCharges checks that bytes held back by the cap of their stream
+// are only taken from the server wide bucket once they move.
+func TestLimiterCharges(t *testing.T) {
+	bw := newBandwidth(10000, 0, 1e6)
+	limit := bw.stream("client")
+	defer limit.close()
+
+	start := time.Now()
+	// The stream bucket starts empty, 1000 Bytes take 100ms.
+	limit.wait(1000)
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("wait took %v, want about 100ms", elapsed)
+	}
+	bw.global.mu.Lock()
+	defer bw.global.mu.Unlock()
+	// Charged as the bytes moved, the global bucket had refilled up to its
+	// burst of 100ms, 100000 Bytes, first.
+	if bw.global.tokens < 0 {
+		t.Errorf("global bucket in debt by %.0f Bytes, charged before the bytes moved", -bw.global.tokens)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// counters tracks requests, errors and bytes per operation since the last
+// reset.
+type counters struct {
+	mu     sync.Mutex
+	ops    map[string]*pb.OperationCounters
+	faults int64
+	since  time.Time
+}
+
+func newCounters() *counters {
+	return &counters{ops: make(map[string]*pb.OperationCounters), since: time.Now()}
+}
+
+func (c *counters) record(op string, failed bool, sent, received int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts, ok := c.ops[op]
+	if !ok {
+		counts = &pb.OperationCounters{}
+		c.ops[op] = counts
+	}
+	counts.Requests++
+	if failed {
+		counts.Errors++
+	}
+	counts.BytesSent += sent
+	counts.BytesReceived += received
+}
+
+func (c *counters) faultInjected() {
+	c.mu.Lock()
+	c.faults++
+	c.mu.Unlock()
+}
+
+func (c *counters) snapshot() *pb.Counters {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snap := &pb.Counters{
+		Operations:     make(map[string]*pb.OperationCounters, len(c.ops)),
+		FaultsInjected: c.faults,
+		Since:          timestamppb.New(c.since),
+	}
+	for op, counts := range c.ops {
+		snap.Operations[op] = proto.Clone(counts).(*pb.OperationCounters)
+	}
+	return snap
+}
+
+// reset clears the counters and returns their last values.
+func (c *counters) reset() *pb.Counters {
+	snap := c.snapshot()
+	c.mu.Lock()
+	c.ops = make(map[string]*pb.OperationCounters)
+	c.faults = 0
+	c.since = time.Now()
+	c.mu.Unlock()
+	return snap
+}
+
+// streamInterceptor counts the FileService streams. The admin service is
+// left out so polling it does not skew the numbers.
+func (c *counters) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, "/proto.FileService/") {
+		return handler(srv, ss)
+	}
+	counted := &countedStream{ServerStream: ss}
+	err := handler(srv, counted)
+	c.record(path.Base(info.FullMethod), err != nil, counted.sent, counted.received)
+	return err
+}
+
+// unaryInterceptor counts the unary FileService calls.
+func (c *counters) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/proto.FileService/") {
+		return handler(ctx, req)
+	}
+	resp, err := handler(ctx, req)
+	c.record(path.Base(info.FullMethod), err != nil, 0, 0)
+	return resp, err
+}
+
+// countedStream adds up the blob bytes moving through a stream.
+type countedStream struct {
+	grpc.ServerStream
+	sent     int64
+	received int64
+}
+
+func (s *countedStream) SendMsg(m interface{}) error {
+	if blob, ok := m.(*pb.FileBlob); ok {
+		s.sent += int64(len(blob.GetBlob()))
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *countedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if blob, ok := m.(*pb.FileBlob); ok && err == nil {
+		s.received += int64(len(blob.GetBlob()))
+	}
+	return err
+}
+
+// countedResponse records the status and body size of an S3 response.
+type countedResponse struct {
+	http.ResponseWriter
+	status int
+	sent   int64
+}
+
+func (w *countedResponse) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countedResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.sent += int64(n)
+	return n, err
+}
+
+// countedBody adds up the bytes read from an S3 request body.
+type countedBody struct {
+	io.ReadCloser
+	received int64
+}
+
+func (b *countedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	return n, err
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// faultRule injects one kind of failure into the requests it matches. Rules
+// are read from JSON such as
+//
+//	[
+//	  {"operation": "GET", "key_prefix": "images/", "probability": 0.05, "action": "truncate", "after": 1048576},
+//	  {"operation": "PUT", "min_size": 104857600, "probability": 0.01, "action": "throttle"}
+//	]
+type faultRule struct {
+	// Operation is one of faultOperations, empty matches them all. Batch
+	// deletes are DELETE, and multipart uploads PUT.
+	Operation string `json:"operation,omitempty"`
+	// KeyPrefix restricts the rule to objects whose key starts with it, or
+	// for LIST to listings whose prefix does.
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	// MinSize and MaxSize bound the transfer size, a zero MaxSize means no
+	// upper bound. Uploads of unknown size and operations other than GET and
+	// PUT, which have no size up front, only match unbounded rules.
+	MinSize int64 `json:"min_size,omitempty"`
+	MaxSize int64 `json:"max_size,omitempty"`
+	// Probability is the chance in [0, 1] that a matching request fails.
+	Probability float64 `json:"probability"`
+	// Action is one of
+	//   unavailable: fail with UNAVAILABLE (HTTP 503 ServiceUnavailable)
+	//   throttle:    fail with RESOURCE_EXHAUSTED (HTTP 503 SlowDown)
+	//   stall:       wait for Stall before handling the request
+	//   truncate:    abort the transfer once After bytes have moved
+	//   corrupt:     flip the byte at offset After
+	// truncate and corrupt only apply to GET and PUT, the operations that
+	// stream bytes.
+	Action string `json:"action"`
+	After  int64  `json:"after,omitempty"`
+	Stall  string `json:"stall,omitempty"`
+
+	stall time.Duration
+}
+
+var (
+	faultActions    = []string{"unavailable", "throttle", "stall", "truncate", "corrupt"}
+	faultOperations = []string{"GET", "PUT", "HEAD", "LIST", "DELETE", "COPY"}
+)
+
+func (r *faultRule) validate() error {
+	known := r.Operation == ""
+	for _, op := range faultOperations {
+		known = known || r.Operation == op
+	}
+	if !known {
+		return fmt.Errorf("unknown operation %q - choose from %v", r.Operation, faultOperations)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability must be within [0, 1]")
+	}
+	if r.MaxSize != 0 && r.MaxSize < r.MinSize {
+		return fmt.Errorf("max_size is below min_size")
+	}
+	if r.streams() && r.Operation != "" && r.Operation != "GET" && r.Operation != "PUT" {
+		return fmt.Errorf("%s only applies to GET and PUT, %s streams no bytes", r.Action, r.Operation)
+	}
+	switch r.Action {
+	case "unavailable", "throttle", "truncate", "corrupt":
+	case "stall":
+		var err error
+		if r.stall, err = time.ParseDuration(r.Stall); err != nil {
+			return fmt.Errorf("invalid stall: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown action %q - choose from %v", r.Action, faultActions)
+	}
+	if r.After < 0 {
+		return fmt.Errorf("after must not be negative")
+	}
+	return nil
+}
+
+// streams tells whether the rule acts on the bytes of a transfer.
+func (r *faultRule) streams() bool {
+	return r.Action == "truncate" || r.Action == "corrupt"
+}
+
+func (r *faultRule) matches(commType, key string, size int64) bool {
+	if r.Operation != "" && r.Operation != commType {
+		return false
+	}
+	if r.streams() && commType != "GET" && commType != "PUT" {
+		return false
+	}
+	if !strings.HasPrefix(key, r.KeyPrefix) {
+		return false
+	}
+	if size < 0 {
+		return r.MinSize == 0 && r.MaxSize == 0
+	}
+	return size >= r.MinSize && (r.MaxSize == 0 || size <= r.MaxSize)
+}
+
+// begin applies the faults that happen before any byte moves.
+func (r *faultRule) begin() error {
+	if r == nil {
+		return nil
+	}
+	switch r.Action {
+	case "unavailable":
+		return status.Error(codes.Unavailable, "service unavailable (injected fault)")
+	case "throttle":
+		return status.Error(codes.ResourceExhausted, "SlowDown: please reduce your request rate (injected fault)")
+	case "stall":
+		time.Sleep(r.stall)
+	}
+	return nil
+}
+
+// apply passes the chunk found at offset of the transfer through the fault.
+// A truncated transfer gets the bytes before the cut along with an error.
+func (r *faultRule) apply(chunk []byte, offset int64) ([]byte, error) {
+	if r == nil || offset+int64(len(chunk)) <= r.After {
+		return chunk, nil
+	}
+	switch r.Action {
+	case "truncate":
+		return chunk[:r.After-offset], status.Errorf(codes.Unavailable, "connection reset after %d bytes (injected fault)", r.After)
+	case "corrupt":
+		if offset <= r.After {
+			corrupted := append([]byte(nil), chunk...)
+			corrupted[r.After-offset] ^= 0xff
+			return corrupted, nil
+		}
+	}
+	return chunk, nil
+}
+
+// faults holds the active rules, which can be replaced at runtime.
+type faults struct {
+	mu    sync.RWMutex
+	rules []faultRule
+}
+
+func loadFaultRules(path string) ([]faultRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []faultRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid fault rules: %v", err)
+	}
+	return rules, nil
+}
+
+func (f *faults) set(rules []faultRule) error {
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return fmt.Errorf("fault rule %d: %v", i, err)
+		}
+	}
+	f.mu.Lock()
+	f.rules = rules
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *faults) get() []faultRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]faultRule(nil), f.rules...)
+}
+
+// pick returns the first matching rule that fires for a transfer, or nil. A
+// negative size means the size is not known.
+func (f *faults) pick(commType, key string, size int64) *faultRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := range f.rules {
+		rule := f.rules[i]
+		if rule.matches(commType, key, size) && rand.Float64() < rule.Probability {
+			return &rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule  faultRule
+		valid bool
+	}{
+		{rule: faultRule{Action: "unavailable"}, valid: true},
+		{rule: faultRule{Operation: "HEAD", Action: "throttle"}, valid: true},
+		{rule: faultRule{Operation: "LIST", Action: "stall", Stall: "1s"}, valid: true},
+		{rule: faultRule{Operation: "DELETE", Action: "unavailable"}, valid: true},
+		{rule: faultRule{Operation: "COPY", Action: "throttle"}, valid: true},
+		{rule: faultRule{Action: "truncate", After: 10}, valid: true},
+		{rule: faultRule{Operation: "GET", Action: "corrupt"}, valid: true},
+		{rule: faultRule{Operation: "POST", Action: "unavailable"}},
+		{rule: faultRule{Operation: "get", Action: "unavailable"}},
+		{rule: faultRule{Operation: "HEAD", Action: "truncate"}},
+		{rule: faultRule{Operation: "COPY", Action: "corrupt"}},
+		{rule: faultRule{Action: "explode"}},
+		{rule: faultRule{Action: "stall", Stall: "soon"}},
+		{rule: faultRule{Action: "unavailable", Probability: 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.rule.Operation+" "+test.rule.Action, func(t *testing.T) {
+			if err := test.rule.validate(); (err == nil) != test.valid {
+				t.Errorf("validate() = %v, want valid %t", err, test.valid)
+			}
+		})
+	}
+}
+
+// TestFaultOperations checks that rules fire on the operations they name,
+// and that faults on the bytes of a transfer leave other operations alone.
+func TestFaultOperations(t *testing.T) {
+	s := newServer(newMemoryStore(), latency.DefaultModel(), newBandwidth(0, 0, 0))
+	ctx := context.Background()
+	ref := &pb.ObjectRef{Bucket: "b", Key: "k"}
+	rules := []faultRule{
+		{Action: "truncate", Probability: 1},
+		{Operation: "HEAD", Action: "unavailable", Probability: 1},
+		{Operation: "LIST", KeyPrefix: "logs/", Action: "throttle", Probability: 1},
+		{Operation: "DELETE", Action: "unavailable", Probability: 1},
+		{Operation: "COPY", Action: "throttle", Probability: 1},
+	}
+	if err := s.faults.set(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.HeadFile(ctx, ref); status.Code(err) != codes.Unavailable {
+		t.Errorf("HeadFile code = %v, want %v", status.Code(err), codes.Unavailable)
+	}
+	if _, err := s.ListFiles(ctx, &pb.ListRequest{Bucket: "b", Prefix: "logs/2024"}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("ListFiles of logs/ code = %v, want %v", status.Code(err), codes.ResourceExhausted)
+	}
+	// Other prefixes get past the fault to the missing bucket.
+	if _, err := s.ListFiles(ctx, &pb.ListRequest{Bucket: "b", Prefix: "images/"}); status.Code(err) != codes.NotFound {
+		t.Errorf("ListFiles of images/ code = %v, want %v", status.Code(err), codes.NotFound)
+	}
+	if _, err := s.DeleteFile(ctx, ref); status.Code(err) != codes.Unavailable {
+		t.Errorf("DeleteFile code = %v, want %v", status.Code(err), codes.Unavailable)
+	}
+	if _, err := s.DeleteFiles(ctx, &pb.DeleteRequest{Bucket: "b", Keys: []string{"k"}}); status.Code(err) != codes.Unavailable {
+		t.Errorf("DeleteFiles code = %v, want %v", status.Code(err), codes.Unavailable)
+	}
+	if _, err := s.CopyFile(ctx, &pb.CopyRequest{Source: ref, Destination: &pb.ObjectRef{Bucket: "b", Key: "copy"}}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("CopyFile code = %v, want %v", status.Code(err), codes.ResourceExhausted)
+	}
+	if got := s.counters.snapshot().GetFaultsInjected(); got != 5 {
+		t.Errorf("%d faults injected, want 5", got)
+	}
+}
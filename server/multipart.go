@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// multipartBucket keeps the parts of multipart uploads in progress, each
+// stored as an object of the backend until the upload completes. Clients
+// cannot address it.
+const multipartBucket = ".mocks3-multipart"
+
+// S3 limits: every part but the last must be at least 5 MiB and part
+// numbers run from 1 to 10000.
+const (
+	minPartSize   = 5 * mib
+	maxPartNumber = 10000
+)
+
+var (
+	errNoSuchUpload     = errors.New("upload not found")
+	errInvalidPart      = errors.New("invalid part")
+	errInvalidPartOrder = errors.New("parts are not in ascending order")
+	errEntityTooSmall   = errors.New("part is smaller than the minimum allowed size")
+	errUploadInProgress = errors.New("upload is being completed")
+)
+
+type multipartUpload struct {
+	bucket     string
+	key        string
+	meta       ObjectMeta
+	parts      map[int32]*pb.UploadedPart
+	completing bool
+}
+
+// multipartUploads tracks the uploads in progress. They are lost when the
+// server restarts, even though the disk backend keeps their parts.
+type multipartUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*multipartUpload
+}
+
+func partKey(uploadID string, partNumber int32) string {
+	return fmt.Sprintf("%s/%05d", uploadID, partNumber)
+}
+
+func (m *multipartUploads) create(bucket, key string, meta ObjectMeta) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	uploadID := hex.EncodeToString(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uploads == nil {
+		m.uploads = make(map[string]*multipartUpload)
+	}
+	m.uploads[uploadID] = &multipartUpload{bucket: bucket, key: key, meta: meta, parts: make(map[int32]*pb.UploadedPart)}
+	return uploadID
+}
+
+// lookup returns the upload, which must be for bucket/key. The caller holds
+// mu.
+func (m *multipartUploads) lookup(uploadID, bucket, key string) (*multipartUpload, error) {
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.bucket != bucket || upload.key != key {
+		return nil, errNoSuchUpload
+	}
+	return upload, nil
+}
+
+// checkPart verifies a part may be uploaded before its bytes are received.
+func (m *multipartUploads) checkPart(uploadID, bucket, key string, partNumber int32) error {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return fmt.Errorf("%w: part number must be within [1, %d], got %d", errInvalidPart, maxPartNumber, partNumber)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.lookup(uploadID, bucket, key)
+	return err
+}
+
+// addPart records a part once it is stored. A part that lost the race with
+// the end of its upload is removed again.
+func (m *multipartUploads) addPart(store Store, uploadID, bucket, key string, part *pb.UploadedPart) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.lookup(uploadID, bucket, key)
+	if err != nil {
+		store.Delete(multipartBucket, partKey(uploadID, part.GetPartNumber()))
+		return err
+	}
+	upload.parts[part.GetPartNumber()] = part
+	return nil
+}
+
+// complete assembles the listed parts into bucket/key, provided cond holds
+// when the object is stored, then drops every part of the upload. An upload
+// that fails, its preconditions included, can be completed again. It returns the object size and its checksums: the S3 style
+// multipart ETag, the MD5 of the part MD5s followed by the number of parts,
+// and the CRC32C of the whole object.
+func (m *multipartUploads) complete(store Store, uploadID, bucket, key string, parts []*pb.UploadedPart, cond object.Preconditions) (int64, object.Checksums, error) {
+	m.mu.Lock()
+	upload, err := m.lookup(uploadID, bucket, key)
+	if err == nil && upload.completing {
+		err = errUploadInProgress
+	}
+	if err == nil {
+		err = upload.check(parts)
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return 0, object.Checksums{}, err
+	}
+	upload.completing = true
+	m.mu.Unlock()
+
+	size, sums, err := assemble(store, uploadID, upload, parts, cond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		upload.completing = false
+		return 0, object.Checksums{}, err
+	}
+	delete(m.uploads, uploadID)
+	removeParts(store, uploadID, upload)
+	return size, sums, nil
+}
+
+// check validates the parts a client completes an upload with. The caller
+// holds mu.
+func (u *multipartUpload) check(parts []*pb.UploadedPart) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: an upload needs at least one part", errInvalidPart)
+	}
+	for i, part := range parts {
+		if i > 0 && part.GetPartNumber() <= parts[i-1].GetPartNumber() {
+			return errInvalidPartOrder
+		}
+		uploaded, ok := u.parts[part.GetPartNumber()]
+		if !ok || strings.Trim(part.GetEtag(), `"`) != uploaded.GetEtag() {
+			return fmt.Errorf("%w: part %d was not uploaded or its ETag does not match", errInvalidPart, part.GetPartNumber())
+		}
+		if i < len(parts)-1 && uploaded.GetSize() < minPartSize {
+			return fmt.Errorf("%w: part %d has %d Bytes", errEntityTooSmall, part.GetPartNumber(), uploaded.GetSize())
+		}
+	}
+	return nil
+}
+
+func assemble(store Store, uploadID string, upload *multipartUpload, parts []*pb.UploadedPart, cond object.Preconditions) (int64, object.Checksums, error) {
+	w, err := store.Create(upload.bucket, upload.key)
+	if err != nil {
+		return 0, object.Checksums{}, err
+	}
+	size := int64(0)
+	digest := object.NewDigester(false, false)
+	etags := md5.New()
+	for _, part := range parts {
+		r, _, err := store.Open(multipartBucket, partKey(uploadID, part.GetPartNumber()))
+		if err != nil {
+			w.Abort()
+			return 0, object.Checksums{}, err
+		}
+		n, err := io.Copy(io.MultiWriter(w, digest), r)
+		r.Close()
+		if err != nil {
+			w.Abort()
+			return 0, object.Checksums{}, err
+		}
+		size += n
+		etag, _ := hex.DecodeString(strings.Trim(part.GetEtag(), `"`))
+		etags.Write(etag)
+	}
+	meta := upload.meta
+	meta.Checksums = digest.Sum()
+	meta.ETag = fmt.Sprintf("%s-%d", hex.EncodeToString(etags.Sum(nil)), len(parts))
+	if err := w.Commit(meta, cond); err != nil {
+		return 0, object.Checksums{}, err
+	}
+	return size, meta.Checksums, nil
+}
+
+// abort drops an upload and its parts. An upload being completed cannot be
+// aborted, its parts are still being read.
+func (m *multipartUploads) abort(store Store, uploadID, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.lookup(uploadID, bucket, key)
+	if err != nil {
+		return err
+	}
+	if upload.completing {
+		return errUploadInProgress
+	}
+	delete(m.uploads, uploadID)
+	removeParts(store, uploadID, upload)
+	return nil
+}
+
+func removeParts(store Store, uploadID string, upload *multipartUpload) {
+	for number := range upload.parts {
+		if err := store.Delete(multipartBucket, partKey(uploadID, number)); err != nil && !errors.Is(err, errNotFound) {
+			log.Warnf("failed to remove part %d of upload %s: %v", number, uploadID, err)
+		}
+	}
+}
+
+// checkUpload validates the upload named by a request.
+func checkUpload(upload *pb.MultipartUpload) error {
+	if err := checkObjectRef(upload.GetObject()); err != nil {
+		return err
+	}
+	if upload.GetUploadId() == "" {
+		return status.Error(codes.InvalidArgument, "upload requires an upload ID")
+	}
+	return nil
+}
+
+func (s *server) CreateMultipartUpload(ctx context.Context, req *pb.NewMultipartUpload) (*pb.MultipartUpload, error) {
+	pace := s.grpcPacer("PUT")
+	ref := req.GetObject()
+	if err := checkObjectRef(ref); err != nil {
+		return nil, err
+	}
+	meta, err := uploadMeta(req.GetMetadata())
+	if err != nil {
+		return nil, storeError(ref, err)
+	}
+	uploadID := s.uploads.create(ref.GetBucket(), ref.GetKey(), meta)
+	log.Debugf("Multipart upload %s started for %s", uploadID, objectName(ref.GetBucket(), ref.GetKey()))
+	pace.advance(0)
+	return &pb.MultipartUpload{Object: ref, UploadId: uploadID}, nil
+}
+
+func (s *server) UploadPart(stream pb.FileService_UploadPartServer) error {
+	pace := s.grpcPacer("PUT")
+	limit := s.bandwidth.stream(peerHost(stream.Context()))
+	defer limit.close()
+
+	// The first chunk names the upload and the part.
+	chunk, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	upload, number := chunk.GetUpload(), chunk.GetPartNumber()
+	if err := checkUpload(upload); err != nil {
+		return err
+	}
+	ref := upload.GetObject()
+	if err := s.uploads.checkPart(upload.GetUploadId(), ref.GetBucket(), ref.GetKey(), number); err != nil {
+		return storeError(ref, err)
+	}
+	w, createErr := s.store.Create(multipartBucket, partKey(upload.GetUploadId(), number))
+	if createErr != nil {
+		return storeError(ref, createErr)
+	}
+	announced := int64(-1)
+	if chunk.GetSize() > 0 {
+		announced = chunk.GetSize()
+	}
+	fault := s.pickFault("PUT", ref.GetKey(), announced)
+	if faultErr := fault.begin(); faultErr != nil {
+		w.Abort()
+		return faultErr
+	}
+
+	digest := object.NewDigester(true, chunk.GetChecksumAlgorithm() == pb.ChecksumAlgorithm_SHA256)
+	size, expected, err := s.receiveBlobs(stream, chunk, err, ref, io.MultiWriter(w, digest), fault, pace, limit)
+	sums := digest.Sum()
+	if err == nil {
+		if err = sums.Verify(expected); err != nil {
+			err = status.Errorf(codes.DataLoss, "part %d of upload %s: %v", number, upload.GetUploadId(), err)
+		}
+	}
+	if err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.Commit(ObjectMeta{Checksums: sums}, object.Preconditions{}); err != nil {
+		return storeError(ref, err)
+	}
+	part := &pb.UploadedPart{PartNumber: number, Etag: sums.ETag, Size: size}
+	if err := s.uploads.addPart(s.store, upload.GetUploadId(), ref.GetBucket(), ref.GetKey(), part); err != nil {
+		return storeError(ref, err)
+	}
+	log.Debugf("Multipart upload %s: part %d, %d Bytes", upload.GetUploadId(), number, size)
+	return stream.SendAndClose(part)
+}
+
+func (s *server) CompleteMultipartUpload(ctx context.Context, req *pb.CompletedMultipartUpload) (*pb.FileSize, error) {
+	pace := s.grpcPacer("PUT")
+	upload := req.GetUpload()
+	if err := checkUpload(upload); err != nil {
+		return nil, err
+	}
+	ref := upload.GetObject()
+	size, sums, err := s.uploads.complete(s.store, upload.GetUploadId(), ref.GetBucket(), ref.GetKey(), req.GetParts(), object.PreconditionsFrom(req.GetPreconditions()))
+	if err != nil {
+		return nil, storeError(ref, err)
+	}
+	log.Debugf("Multipart upload %s completed %s, %d Bytes in %d parts, ETag %s", upload.GetUploadId(), objectName(ref.GetBucket(), ref.GetKey()), size, len(req.GetParts()), sums.ETag)
+	pace.advance(0)
+	return &pb.FileSize{Size: size, Object: ref, Checksums: sums.Proto()}, nil
+}
+
+func (s *server) AbortMultipartUpload(ctx context.Context, upload *pb.MultipartUpload) (*emptypb.Empty, error) {
+	pace := s.grpcPacer("PUT")
+	if err := checkUpload(upload); err != nil {
+		return nil, err
+	}
+	ref := upload.GetObject()
+	if err := s.uploads.abort(s.store, upload.GetUploadId(), ref.GetBucket(), ref.GetKey()); err != nil {
+		return nil, storeError(ref, err)
+	}
+	log.Debugf("Multipart upload %s aborted", upload.GetUploadId())
+	pace.advance(0)
+	return &emptypb.Empty{}, nil
+}
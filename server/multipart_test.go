@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uploadPart sends data in chunks of at most 1 MiB, under the gRPC message
+// size limit.
+func uploadPart(client pb.FileServiceClient, upload *pb.MultipartUpload, number int32, data []byte) (*pb.UploadedPart, error) {
+	stream, err := client.UploadPart(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	blob := &pb.FileBlob{Upload: upload, PartNumber: number}
+	for {
+		n := len(data)
+		if n > mib {
+			n = mib
+		}
+		blob.Blob, data = data[:n], data[n:]
+		if err := stream.Send(blob); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return stream.CloseAndRecv()
+		}
+		blob = &pb.FileBlob{}
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	store := newMemoryStore()
+	client := newTestClient(t, store)
+	ctx := context.Background()
+	ref := &pb.ObjectRef{Bucket: "b", Key: "k"}
+	first, last := bytes.Repeat([]byte("a"), minPartSize), []byte("tail")
+
+	start := func(t *testing.T) (*pb.MultipartUpload, []*pb.UploadedPart) {
+		t.Helper()
+		upload, err := client.CreateMultipartUpload(ctx, &pb.NewMultipartUpload{Object: ref, Metadata: &pb.ObjectMetadata{ContentType: "text/plain"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Parts may arrive in any order.
+		two, err := uploadPart(client, upload, 2, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		one, err := uploadPart(client, upload, 1, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return upload, []*pb.UploadedPart{one, two}
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		upload, parts := start(t)
+		if _, err := client.CompleteMultipartUpload(ctx, &pb.CompletedMultipartUpload{Upload: upload, Parts: []*pb.UploadedPart{parts[1], parts[0]}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("completing with parts out of order: code = %v, want %v", status.Code(err), codes.InvalidArgument)
+		}
+		res, err := client.CompleteMultipartUpload(ctx, &pb.CompletedMultipartUpload{Upload: upload, Parts: parts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(len(first) + len(last)); res.GetSize() != want || !strings.HasSuffix(res.GetChecksums().GetEtag(), "-2") {
+			t.Errorf("completed %d Bytes with ETag %s, want %d Bytes with a 2 part ETag", res.GetSize(), res.GetChecksums().GetEtag(), want)
+		}
+		data, blob, _, err := getFile(client, &pb.FileSize{Object: ref})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, append(append([]byte{}, first...), last...)) || blob.GetMetadata().GetContentType() != "text/plain" {
+			t.Errorf("read %d Bytes of %s, want the parts in order as text/plain", len(data), blob.GetMetadata().GetContentType())
+		}
+		if parts, _ := store.List(multipartBucket, upload.GetUploadId()); len(parts) != 0 {
+			t.Errorf("%d parts left after completing", len(parts))
+		}
+		if _, err := client.CompleteMultipartUpload(ctx, &pb.CompletedMultipartUpload{Upload: upload, Parts: parts}); status.Code(err) != codes.NotFound {
+			t.Errorf("completing twice: code = %v, want %v", status.Code(err), codes.NotFound)
+		}
+	})
+
+	t.Run("small part", func(t *testing.T) {
+		upload, parts := start(t)
+		small, err := uploadPart(client, upload, 1, last)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.CompleteMultipartUpload(ctx, &pb.CompletedMultipartUpload{Upload: upload, Parts: []*pb.UploadedPart{small, parts[1]}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("completing with a small first part: code = %v, want %v", status.Code(err), codes.InvalidArgument)
+		}
+	})
+
+	t.Run("preconditions", func(t *testing.T) {
+		mustPutFile(t, client, "b", "k", []byte("existing"))
+		upload, parts := start(t)
+		req := &pb.CompletedMultipartUpload{Upload: upload, Parts: parts, Preconditions: &pb.Preconditions{IfNoneMatch: "*"}}
+		if _, err := client.CompleteMultipartUpload(ctx, req); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("completing onto an object with If-None-Match *: code = %v, want %v", status.Code(err), codes.FailedPrecondition)
+		}
+		if data, _, _, err := getFile(client, &pb.FileSize{Object: ref}); err != nil || string(data) != "existing" {
+			t.Errorf("object after a failed precondition = %q, %v, want existing", data, err)
+		}
+		// The upload survives and can still be completed.
+		req.Preconditions = nil
+		if _, err := client.CompleteMultipartUpload(ctx, req); err != nil {
+			t.Errorf("completing again: %v", err)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		upload, _ := start(t)
+		if _, err := client.AbortMultipartUpload(ctx, upload); err != nil {
+			t.Fatal(err)
+		}
+		if parts, _ := store.List(multipartBucket, upload.GetUploadId()); len(parts) != 0 {
+			t.Errorf("%d parts left after aborting", len(parts))
+		}
+		if _, err := uploadPart(client, upload, 3, last); status.Code(err) != codes.NotFound {
+			t.Errorf("uploading to an aborted upload: code = %v, want %v", status.Code(err), codes.NotFound)
+		}
+		if _, err := client.AbortMultipartUpload(ctx, upload); status.Code(err) != codes.NotFound {
+			t.Errorf("aborting twice: code = %v, want %v", status.Code(err), codes.NotFound)
+		}
+	})
+}
+
+// TestMultipartCompleting checks that an upload being completed can neither
+// be completed again nor aborted, and is reported as a conflict.
+func TestMultipartCompleting(t *testing.T) {
+	var uploads multipartUploads
+	store := newMemoryStore()
+	uploadID := uploads.create("b", "k", ObjectMeta{})
+	uploads.uploads[uploadID].parts[1] = &pb.UploadedPart{PartNumber: 1, Etag: "etag"}
+	uploads.uploads[uploadID].completing = true
+
+	parts := []*pb.UploadedPart{{PartNumber: 1, Etag: "etag"}}
+	if _, _, err := uploads.complete(store, uploadID, "b", "k", parts, object.Preconditions{}); !errors.Is(err, errUploadInProgress) {
+		t.Errorf("complete error = %v, want %v", err, errUploadInProgress)
+	}
+	if err := uploads.abort(store, uploadID, "b", "k"); !errors.Is(err, errUploadInProgress) {
+		t.Errorf("abort error = %v, want %v", err, errUploadInProgress)
+	}
+	if code := status.Code(storeError(&pb.ObjectRef{Bucket: "b", Key: "k"}, errUploadInProgress)); code != codes.Aborted {
+		t.Errorf("gRPC code = %v, want %v", code, codes.Aborted)
+	}
+	if code, _, _ := s3StoreError(errUploadInProgress); code != http.StatusConflict {
+		t.Errorf("HTTP status = %d, want 409", code)
+	}
+}
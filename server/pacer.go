@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// pacer enforces the latency model on one transfer. The model gives the
+// cumulative time to move a given number of bytes, so its value at zero bytes
+// is the time to first byte and every later chunk is held back until the
+// model says it is due.
+type pacer struct {
+	sample latency.Sample
+	start  time.Time
+	done   int64
+}
+
+// newPacer starts pacing a commType transfer that began at start. The whole
+// transfer is paced against a single draw from the model.
+func (s *server) newPacer(commType string, start time.Time) *pacer {
+	return &pacer{sample: s.model.Load().Sample(commType), start: start}
+}
+
+// grpcPacer is like newPacer but returns nil, which never waits, unless the
+// server enforces latency on the gRPC FileService.
+func (s *server) grpcPacer(commType string) *pacer {
+	if *latencyMode != "server" {
+		return nil
+	}
+	return s.newPacer(commType, time.Now())
+}
+
+// pacedHeader is set on the response headers of the FileService calls the
+// server paces, so that clients do not pad them out to their own model again.
+const pacedHeader = "mocks3-paced"
+
+func pacesFileService(fullMethod string) bool {
+	return *latencyMode == "server" && strings.HasPrefix(fullMethod, "/proto.FileService/")
+}
+
+// pacedStreamInterceptor announces that the server paces a stream.
+func pacedStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if pacesFileService(info.FullMethod) {
+		ss.SetHeader(metadata.Pairs(pacedHeader, "true"))
+	}
+	return handler(srv, ss)
+}
+
+// pacedUnaryInterceptor announces that the server paces a unary call.
+func pacedUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if pacesFileService(info.FullMethod) {
+		grpc.SetHeader(ctx, metadata.Pairs(pacedHeader, "true"))
+	}
+	return handler(ctx, req)
+}
+
+// advance accounts for n more bytes and sleeps until they are due. Calling it
+// with zero waits for the time to first byte, or for the whole transfer once
+// every byte has been accounted for.
+func (p *pacer) advance(n int) {
+	if p == nil {
+		return
+	}
+	p.done += int64(n)
+	time.Sleep(p.sample.At(p.done) - time.Since(p.start))
+}
+
+// pacedReader paces the bytes read from an upload body.
+type pacedReader struct {
+	io.Reader
+	pace *pacer
+}
+
+func (r *pacedReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.pace.advance(n)
+	return n, err
+}
+
+// pacedWriter paces the bytes written to a download body.
+type pacedWriter struct {
+	io.Writer
+	pace *pacer
+}
+
+func (w *pacedWriter) Write(b []byte) (int, error) {
+	w.pace.advance(len(b))
+	return w.Writer.Write(b)
+}
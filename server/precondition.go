@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+var (
+	errPreconditionFailed = errors.New("at least one of the preconditions did not hold")
+	// errNotModified is not a failure, it tells a conditional read that the
+	// object it already has is current.
+	errNotModified = errors.New("not modified")
+)
+
+// evaluate checks p, the preconditions of a request, against info, the
+// object it targets, which is nil when there is none. The rules are those of
+// RFC 9110: IfUnmodifiedSince only counts without IfMatch and IfModifiedSince
+// only without IfNoneMatch. A read that fails IfNoneMatch or IfModifiedSince
+// gets errNotModified, a write errPreconditionFailed, and IfModifiedSince
+// does not apply to writes. Writing with IfMatch to a missing object fails
+// with errNotFound, like S3.
+func evaluate(p object.Preconditions, info *ObjectInfo, read bool) error {
+	if p.IfMatch != "" {
+		if info == nil {
+			return errNotFound
+		}
+		if !etagMatches(p.IfMatch, info.ETag) {
+			return errPreconditionFailed
+		}
+	} else if !p.IfUnmodifiedSince.IsZero() && info != nil && modifiedSince(*info, p.IfUnmodifiedSince) {
+		return errPreconditionFailed
+	}
+
+	if p.IfNoneMatch != "" {
+		if info != nil && etagMatches(p.IfNoneMatch, info.ETag) {
+			if read {
+				return errNotModified
+			}
+			return errPreconditionFailed
+		}
+	} else if read && !p.IfModifiedSince.IsZero() && info != nil && !modifiedSince(*info, p.IfModifiedSince) {
+		return errNotModified
+	}
+	return nil
+}
+
+// etagMatches tells whether an existing object with etag is in list.
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.Trim(strings.TrimPrefix(candidate, "W/"), `"`)
+		if etag != "" && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince compares at the one second resolution of HTTP dates, so a
+// client can send back the Last-Modified it was given.
+func modifiedSince(info ObjectInfo, t time.Time) bool {
+	return info.LastModified.Truncate(time.Second).After(t)
+}
+
+// checkRead evaluates the preconditions of a read of bucket/key. A read that
+// gets errNotModified also gets the info of the object to answer with.
+func checkRead(store Store, bucket, key string, cond object.Preconditions) (ObjectInfo, error) {
+	if cond.IsZero() {
+		return ObjectInfo{}, nil
+	}
+	info, err := store.Stat(bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return info, evaluate(cond, &info, true)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+	info := &ObjectInfo{Key: "k", LastModified: modified}
+	info.ETag = "abc"
+	earlier, later := modified.Add(-time.Hour), modified.Add(time.Hour)
+	// An HTTP date drops the fraction of a second of Last-Modified.
+	asSent := modified.Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		cond object.Preconditions
+		info *ObjectInfo
+		read bool
+		err  error
+	}{
+		{name: "none", info: info, read: true},
+		{name: "none on a missing object", info: nil},
+		{name: "if-match", cond: object.Preconditions{IfMatch: "abc"}, info: info},
+		{name: "if-match quoted", cond: object.Preconditions{IfMatch: `"abc"`}, info: info},
+		{name: "if-match list", cond: object.Preconditions{IfMatch: `"x", "abc"`}, info: info},
+		{name: "if-match any", cond: object.Preconditions{IfMatch: "*"}, info: info},
+		{name: "if-match mismatch", cond: object.Preconditions{IfMatch: "xyz"}, info: info, err: errPreconditionFailed},
+		{name: "if-match mismatch read", cond: object.Preconditions{IfMatch: "xyz"}, info: info, read: true, err: errPreconditionFailed},
+		{name: "if-match missing object", cond: object.Preconditions{IfMatch: "*"}, info: nil, err: errNotFound},
+		{name: "if-none-match read", cond: object.Preconditions{IfNoneMatch: "abc"}, info: info, read: true, err: errNotModified},
+		{name: "if-none-match weak read", cond: object.Preconditions{IfNoneMatch: `W/"abc"`}, info: info, read: true, err: errNotModified},
+		{name: "if-none-match write", cond: object.Preconditions{IfNoneMatch: "abc"}, info: info, err: errPreconditionFailed},
+		{name: "if-none-match other etag", cond: object.Preconditions{IfNoneMatch: "xyz"}, info: info, read: true},
+		{name: "create only", cond: object.Preconditions{IfNoneMatch: "*"}, info: nil},
+		{name: "create only existing", cond: object.Preconditions{IfNoneMatch: "*"}, info: info, err: errPreconditionFailed},
+		{name: "if-modified-since earlier", cond: object.Preconditions{IfModifiedSince: earlier}, info: info, read: true},
+		{name: "if-modified-since later", cond: object.Preconditions{IfModifiedSince: later}, info: info, read: true, err: errNotModified},
+		{name: "if-modified-since last modified", cond: object.Preconditions{IfModifiedSince: asSent}, info: info, read: true, err: errNotModified},
+		{name: "if-modified-since write", cond: object.Preconditions{IfModifiedSince: later}, info: info},
+		{name: "if-modified-since missing object", cond: object.Preconditions{IfModifiedSince: later}, info: nil, read: true},
+		{name: "if-unmodified-since later", cond: object.Preconditions{IfUnmodifiedSince: later}, info: info},
+		{name: "if-unmodified-since last modified", cond: object.Preconditions{IfUnmodifiedSince: asSent}, info: info},
+		{name: "if-unmodified-since earlier", cond: object.Preconditions{IfUnmodifiedSince: earlier}, info: info, err: errPreconditionFailed},
+		{name: "if-unmodified-since earlier read", cond: object.Preconditions{IfUnmodifiedSince: earlier}, info: info, read: true, err: errPreconditionFailed},
+		// RFC 9110 ignores the date conditions when the matching ETag
+		// condition is present.
+		{name: "if-match overrides if-unmodified-since", cond: object.Preconditions{IfMatch: "abc", IfUnmodifiedSince: earlier}, info: info},
+		{name: "if-none-match overrides if-modified-since", cond: object.Preconditions{IfNoneMatch: "xyz", IfModifiedSince: later}, info: info, read: true},
+		{name: "if-match checked first", cond: object.Preconditions{IfMatch: "xyz", IfNoneMatch: "abc"}, info: info, read: true, err: errPreconditionFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := evaluate(test.cond, test.info, test.read); !errors.Is(err, test.err) {
+				t.Errorf("evaluate = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
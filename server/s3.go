@@ -0,0 +1,1004 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// s3Handler serves the subset of the S3 REST API used by our functions, so an
+// unchanged SDK can target mocks3 through an endpoint override. Only path
+// style addressing (/<bucket>/<key>) is supported and requests are not
+// authenticated. Like S3, and unlike the FileService, which has no way to
+// create a bucket and creates them on upload, objects can only be written to
+// buckets made with CreateBucket.
+type s3Handler struct {
+	server *server
+}
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	KeyCount              int              `xml:"KeyCount"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int32  `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+type deleteObjectsRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string `xml:"Key"`
+}
+
+type s3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []s3Deleted     `xml:"Deleted"`
+	Errors  []s3DeleteError `xml:"Error"`
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+func (h *s3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	op := s3Operation(r, bucket, key)
+	log.Debugf("S3 %s %s %s", op, r.Method, r.URL.RequestURI())
+	if op == "" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", r.Method+" "+r.URL.Path+" is not supported")
+		return
+	}
+	if bucket == multipartBucket {
+		writeStoreError(w, r, errInvalidName)
+		return
+	}
+
+	counted := &countedResponse{ResponseWriter: w}
+	body := &countedBody{ReadCloser: r.Body}
+	r.Body = body
+	defer func() {
+		h.server.counters.record(op, counted.status >= 400, counted.sent, body.received)
+	}()
+
+	switch op {
+	case "CreateBucket":
+		h.createBucket(counted, r, bucket, start)
+	case "HeadBucket":
+		h.headBucket(counted, r, bucket, start)
+	case "ListObjectsV2":
+		h.listObjectsV2(counted, r, bucket, start)
+	case "PutObject":
+		h.putObject(counted, r, bucket, key, start)
+	case "GetObject":
+		h.getObject(counted, r, bucket, key, start)
+	case "HeadObject":
+		h.headObject(counted, r, bucket, key, start)
+	case "DeleteObject":
+		h.deleteObject(counted, r, bucket, key, start)
+	case "DeleteObjects":
+		h.deleteObjects(counted, r, bucket, start)
+	case "CopyObject":
+		h.copyObject(counted, r, bucket, key, start)
+	case "CreateMultipartUpload":
+		h.createMultipartUpload(counted, r, bucket, key, start)
+	case "UploadPart":
+		h.uploadPart(counted, r, bucket, key, start)
+	case "CompleteMultipartUpload":
+		h.completeMultipartUpload(counted, r, bucket, key, start)
+	case "AbortMultipartUpload":
+		h.abortMultipartUpload(counted, r, bucket, key, start)
+	}
+}
+
+// s3Operation names the S3 call a request makes, or returns "" when it is
+// not supported. ListBuckets and virtual host style requests are not.
+func s3Operation(r *http.Request, bucket, key string) string {
+	switch {
+	case bucket == "":
+		return ""
+	case key == "":
+		switch r.Method {
+		case http.MethodPut:
+			return "CreateBucket"
+		case http.MethodHead:
+			return "HeadBucket"
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				return "ListObjectsV2"
+			}
+		case http.MethodPost:
+			if _, ok := r.URL.Query()["delete"]; ok {
+				return "DeleteObjects"
+			}
+		}
+	default:
+		query := r.URL.Query()
+		_, uploads := query["uploads"]
+		uploadID := query.Get("uploadId")
+		copySource := r.Header.Get("X-Amz-Copy-Source") != ""
+		switch {
+		case r.Method == http.MethodPut && copySource && uploadID != "":
+			// UploadPartCopy is not supported.
+			return ""
+		case r.Method == http.MethodPut && copySource:
+			return "CopyObject"
+		case r.Method == http.MethodPost && uploads:
+			return "CreateMultipartUpload"
+		case r.Method == http.MethodPut && uploadID != "" && query.Get("partNumber") != "":
+			return "UploadPart"
+		case r.Method == http.MethodPost && uploadID != "":
+			return "CompleteMultipartUpload"
+		case r.Method == http.MethodDelete && uploadID != "":
+			return "AbortMultipartUpload"
+		case uploadID != "":
+			// ListParts is not supported.
+			return ""
+		}
+		switch r.Method {
+		case http.MethodPut:
+			return "PutObject"
+		case http.MethodGet:
+			return "GetObject"
+		case http.MethodHead:
+			return "HeadObject"
+		case http.MethodDelete:
+			return "DeleteObject"
+		}
+	}
+	return ""
+}
+
+func (h *s3Handler) createBucket(w http.ResponseWriter, r *http.Request, bucket string, start time.Time) {
+	if err := h.server.store.CreateBucket(bucket); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	h.server.newPacer("PUT", start).advance(0)
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *s3Handler) headBucket(w http.ResponseWriter, r *http.Request, bucket string, start time.Time) {
+	if _, err := h.server.store.List(bucket, ""); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	h.server.newPacer("GET", start).advance(0)
+	w.WriteHeader(http.StatusOK)
+}
+
+// checkBucketExists fails writes to a bucket that was never created with
+// errBucketNotFound. Listing under key keeps the lookup short.
+func checkBucketExists(store Store, bucket, key string) error {
+	_, err := store.List(bucket, key)
+	return err
+}
+
+// uploadBody returns the payload of an upload request, decoding aws-chunked
+// bodies, along with the payload size the client announced.
+func uploadBody(r *http.Request) (io.Reader, int64) {
+	body := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body = newAWSChunkedReader(r.Body)
+	}
+	announced := r.ContentLength
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+		announced, _ = strconv.ParseInt(decoded, 10, 64)
+	}
+	return body, announced
+}
+
+// amzMetaPrefix starts the headers carrying user metadata.
+const amzMetaPrefix = "X-Amz-Meta-"
+
+// requestMeta reads the metadata an upload sets through its headers.
+func requestMeta(r *http.Request) (ObjectMeta, error) {
+	var user map[string]string
+	for name, values := range r.Header {
+		if !strings.HasPrefix(name, amzMetaPrefix) {
+			continue
+		}
+		if user == nil {
+			user = make(map[string]string)
+		}
+		user[strings.TrimPrefix(name, amzMetaPrefix)] = strings.Join(values, ",")
+	}
+	return newObjectMeta(r.Header.Get("Content-Type"), user, r.Header.Get("X-Amz-Storage-Class"))
+}
+
+func (h *s3Handler) putObject(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if err := checkBucketExists(h.server.store, bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	meta, err := requestMeta(r)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	raw, announced := uploadBody(r)
+	fault := h.server.pickFault("PUT", key, announced)
+	if err := fault.begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	pace := h.server.newPacer("PUT", start)
+	limit := h.server.bandwidth.stream(remoteHost(r.RemoteAddr))
+	defer limit.close()
+	body := &faultReader{Reader: &throttledReader{Reader: &pacedReader{Reader: raw, pace: pace}, limit: limit}, fault: fault}
+
+	ow, err := h.server.store.Create(bucket, key)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	digest := object.NewDigester(true, wantSHA256(r))
+	size, err := io.Copy(ow, io.TeeReader(body, digest))
+	if _, injected := status.FromError(err); err != nil && injected {
+		ow.Abort()
+		writeFaultError(w, r, err)
+		return
+	} else if err != nil {
+		ow.Abort()
+		writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	meta.Checksums = digest.Sum()
+	if err := verifyUpload(r, raw, meta.Checksums); err != nil {
+		ow.Abort()
+		writeStoreError(w, r, err)
+		return
+	}
+	if err := ow.Commit(meta, requestPreconditions(r)); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	log.Debugf("S3 PUT: %s, %d Bytes, ETag %s", objectName(bucket, key), size, meta.ETag)
+
+	pace.advance(0)
+	setChecksumHeaders(w, meta.Checksums)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *s3Handler) getObject(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if info, err := checkRead(h.server.store, bucket, key, requestPreconditions(r)); errors.Is(err, errNotModified) {
+		h.server.newPacer("HEAD", start).advance(0)
+		writeNotModified(w, info)
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	offset, length := int64(0), int64(0)
+	ranged := false
+	if header := r.Header.Get("Range"); header != "" {
+		info, err := h.server.store.Stat(bucket, key)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		if offset, length, ranged, err = parseRange(header, info.Size); err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			writeStoreError(w, r, err)
+			return
+		}
+	}
+	rc, info, first, size, err := openRange(h.server.store, bucket, key, offset, length)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	defer rc.Close()
+	log.Debugf("S3 GET: %s, %d of %d Bytes from %d", objectName(bucket, key), size, info.Size, first)
+
+	fault := h.server.pickFault("GET", key, size)
+	if err := fault.begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	pace := h.server.newPacer("GET", start)
+	limit := h.server.bandwidth.stream(remoteHost(r.RemoteAddr))
+	defer limit.close()
+	pace.advance(0)
+	setObjectHeaders(w, info)
+	if !ranged && checksumMode(r) {
+		setChecksumHeaders(w, info.Checksums)
+	}
+	if ranged {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, first+size-1, info.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	body := &throttledWriter{Writer: &pacedWriter{Writer: &faultWriter{Writer: w, fault: fault}, pace: pace}, limit: limit}
+	if _, err := io.CopyBuffer(body, rc, make([]byte, 1024*1024)); err != nil {
+		log.Debugf("S3 GET: %s aborted: %v", objectName(bucket, key), err)
+		// Drop the connection so the client sees the body end early.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (h *s3Handler) headObject(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if err := h.server.pickFault("HEAD", key, -1).begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	info, err := h.server.store.Stat(bucket, key)
+	if err == nil {
+		err = evaluate(requestPreconditions(r), &info, true)
+	}
+	if errors.Is(err, errNotModified) {
+		h.server.newPacer("HEAD", start).advance(0)
+		writeNotModified(w, info)
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	h.server.newPacer("HEAD", start).advance(0)
+	setObjectHeaders(w, info)
+	if checksumMode(r) {
+		setChecksumHeaders(w, info.Checksums)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *s3Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if err := h.server.pickFault("DELETE", key, -1).begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	if err := deleteObject(h.server.store, bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	h.server.newPacer("DELETE", start).advance(0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *s3Handler) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string, start time.Time) {
+	var req deleteObjectsRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > maxDeleteKeys {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML", fmt.Sprintf("A batch delete takes from 1 to %d keys.", maxDeleteKeys))
+		return
+	}
+	if err := h.server.pickFault("DELETE", "", -1).begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	result := deleteResult{Xmlns: s3Namespace}
+	for _, obj := range req.Objects {
+		err := errInvalidName
+		if obj.Key != "" {
+			err = deleteObject(h.server.store, bucket, obj.Key)
+		}
+		if err != nil {
+			_, code, message := s3StoreError(err)
+			result.Errors = append(result.Errors, s3DeleteError{Key: obj.Key, Code: code, Message: message})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, s3Deleted{Key: obj.Key})
+		}
+	}
+	log.Debugf("S3 DELETE: %d keys of %s, %d failed", len(req.Objects), bucket, len(result.Errors))
+	h.server.newPacer("BATCH_DELETE", start).advance(len(req.Objects))
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *s3Handler) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	// The source is bucket/key, URL encoded, with an optional leading slash
+	// and version, which is ignored.
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	source, _, _ = strings.Cut(source, "?")
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if err != nil || srcBucket == "" || srcKey == "" {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+		return
+	}
+	if srcBucket == multipartBucket {
+		writeStoreError(w, r, errInvalidName)
+		return
+	}
+	var meta *ObjectMeta
+	switch r.Header.Get("X-Amz-Metadata-Directive") {
+	case "", "COPY":
+	case "REPLACE":
+		replaced, err := requestMeta(r)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		meta = &replaced
+	default:
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Unknown metadata directive.")
+		return
+	}
+	if err := checkBucketExists(h.server.store, bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if err := h.server.pickFault("COPY", key, -1).begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	info, err := copyObject(h.server.store, srcBucket, srcKey, bucket, key, meta)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	log.Debugf("S3 COPY: %s to %s, %d Bytes", objectName(srcBucket, srcKey), objectName(bucket, key), info.Size)
+	h.server.newPacer("COPY", start).advance(int(info.Size))
+	writeXML(w, http.StatusOK, copyObjectResult{
+		Xmlns:        s3Namespace,
+		LastModified: info.LastModified.UTC().Format("2006-01-02T15:04:05.000Z"),
+		ETag:         `"` + info.ETag + `"`,
+	})
+}
+
+func (h *s3Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if err := checkBucketExists(h.server.store, bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	meta, err := requestMeta(r)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	uploadID := h.server.uploads.create(bucket, key, meta)
+	log.Debugf("S3 multipart upload %s started for %s", uploadID, objectName(bucket, key))
+	h.server.newPacer("PUT", start).advance(0)
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{Xmlns: s3Namespace, Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (h *s3Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	uploadID := r.URL.Query().Get("uploadId")
+	number, err := strconv.ParseInt(r.URL.Query().Get("partNumber"), 10, 32)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+		return
+	}
+	if err := h.server.uploads.checkPart(uploadID, bucket, key, int32(number)); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	raw, announced := uploadBody(r)
+	fault := h.server.pickFault("PUT", key, announced)
+	if err := fault.begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	pace := h.server.newPacer("PUT", start)
+	limit := h.server.bandwidth.stream(remoteHost(r.RemoteAddr))
+	defer limit.close()
+	body := &faultReader{Reader: &throttledReader{Reader: &pacedReader{Reader: raw, pace: pace}, limit: limit}, fault: fault}
+
+	ow, err := h.server.store.Create(multipartBucket, partKey(uploadID, int32(number)))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	digest := object.NewDigester(true, wantSHA256(r))
+	size, err := io.Copy(ow, io.TeeReader(body, digest))
+	if _, injected := status.FromError(err); err != nil && injected {
+		ow.Abort()
+		writeFaultError(w, r, err)
+		return
+	} else if err != nil {
+		ow.Abort()
+		writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	sums := digest.Sum()
+	if err := verifyUpload(r, raw, sums); err != nil {
+		ow.Abort()
+		writeStoreError(w, r, err)
+		return
+	}
+	if err := ow.Commit(ObjectMeta{Checksums: sums}, object.Preconditions{}); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	part := &pb.UploadedPart{PartNumber: int32(number), Etag: sums.ETag, Size: size}
+	if err := h.server.uploads.addPart(h.server.store, uploadID, bucket, key, part); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	log.Debugf("S3 multipart upload %s: part %d, %d Bytes", uploadID, number, size)
+
+	pace.advance(0)
+	setChecksumHeaders(w, sums)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *s3Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	uploadID := r.URL.Query().Get("uploadId")
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	parts := make([]*pb.UploadedPart, len(req.Parts))
+	for i, part := range req.Parts {
+		parts[i] = &pb.UploadedPart{PartNumber: part.PartNumber, Etag: part.ETag}
+	}
+	size, sums, err := h.server.uploads.complete(h.server.store, uploadID, bucket, key, parts, requestPreconditions(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	log.Debugf("S3 multipart upload %s completed %s, %d Bytes in %d parts", uploadID, objectName(bucket, key), size, len(parts))
+	h.server.newPacer("PUT", start).advance(0)
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     `"` + sums.ETag + `"`,
+	})
+}
+
+func (h *s3Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string, start time.Time) {
+	if err := h.server.uploads.abort(h.server.store, r.URL.Query().Get("uploadId"), bucket, key); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	h.server.newPacer("PUT", start).advance(0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *s3Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string, start time.Time) {
+	query := r.URL.Query()
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		MaxKeys:           maxListKeys,
+		ContinuationToken: query.Get("continuation-token"),
+		StartAfter:        query.Get("start-after"),
+	}
+	if maxKeys := query.Get("max-keys"); maxKeys != "" {
+		n, err := strconv.Atoi(maxKeys)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+			return
+		}
+		if n < result.MaxKeys {
+			result.MaxKeys = n
+		}
+	}
+	startAfter := result.StartAfter
+	if result.ContinuationToken != "" {
+		var err error
+		if startAfter, err = parseContinuationToken(result.ContinuationToken); err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+			return
+		}
+	}
+
+	if err := h.server.pickFault("LIST", result.Prefix, -1).begin(); err != nil {
+		writeFaultError(w, r, err)
+		return
+	}
+	objects, prefixes, next, truncated, err := listPage(h.server.store, bucket, result.Prefix, result.Delimiter, startAfter, result.MaxKeys)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	for _, obj := range objects {
+		result.Contents = append(result.Contents, s3Object{
+			Key:          obj.Key,
+			LastModified: obj.LastModified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Size:         obj.Size,
+			StorageClass: obj.StorageClass,
+		})
+	}
+	for _, prefix := range prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: prefix})
+	}
+	result.KeyCount = len(objects) + len(prefixes)
+	if truncated {
+		result.IsTruncated = true
+		result.NextContinuationToken = continuationToken(next)
+	}
+
+	// Listings cost more the more entries they return.
+	h.server.newPacer("LIST", start).advance(result.KeyCount)
+	writeXML(w, http.StatusOK, result)
+}
+
+// parseRange reads a single range Range header, as S3 supports, into an
+// offset and length. Other forms of the header are ignored like S3 does.
+func parseRange(header string, size int64) (offset, length int64, ranged bool, err error) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	firstText, lastText, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false, nil
+	}
+	if firstText == "" {
+		// bytes=-n asks for the last n bytes
+		n, err := strconv.ParseInt(lastText, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, fmt.Errorf("%w: %s", errInvalidRange, header)
+		}
+		if n > size {
+			n = size
+		}
+		if n == 0 {
+			return 0, 0, false, fmt.Errorf("%w: %s", errInvalidRange, header)
+		}
+		return size - n, n, true, nil
+	}
+	first, err := strconv.ParseInt(firstText, 10, 64)
+	if err != nil || first >= size {
+		return 0, 0, false, fmt.Errorf("%w: %s", errInvalidRange, header)
+	}
+	if lastText == "" {
+		return first, size - first, true, nil
+	}
+	last, err := strconv.ParseInt(lastText, 10, 64)
+	if err != nil || last < first {
+		return 0, 0, false, fmt.Errorf("%w: %s", errInvalidRange, header)
+	}
+	return first, last - first + 1, true, nil
+}
+
+func setObjectHeaders(w http.ResponseWriter, info ObjectInfo) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	for name, value := range info.UserMetadata {
+		w.Header().Set(amzMetaPrefix+name, value)
+	}
+	// Like S3, only report classes other than the default.
+	if info.StorageClass != "STANDARD" {
+		w.Header().Set("X-Amz-Storage-Class", info.StorageClass)
+	}
+	if info.ETag != "" {
+		w.Header().Set("ETag", `"`+info.ETag+`"`)
+	}
+}
+
+// requestPreconditions reads the conditional headers of a request. Like
+// net/http, it ignores dates it cannot parse.
+func requestPreconditions(r *http.Request) object.Preconditions {
+	cond := object.Preconditions{IfMatch: r.Header.Get("If-Match"), IfNoneMatch: r.Header.Get("If-None-Match")}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		cond.IfModifiedSince = since
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil {
+		cond.IfUnmodifiedSince = since
+	}
+	return cond
+}
+
+// writeNotModified answers a conditional read of an unchanged object.
+func writeNotModified(w http.ResponseWriter, info ObjectInfo) {
+	if info.ETag != "" {
+		w.Header().Set("ETag", `"`+info.ETag+`"`)
+	}
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// checksumMode tells whether a GET or HEAD asks for the checksums of the
+// object. Like S3, ranged reads never get them.
+func checksumMode(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("X-Amz-Checksum-Mode"), "ENABLED")
+}
+
+func setChecksumHeaders(w http.ResponseWriter, sums object.Checksums) {
+	if sums.ETag != "" {
+		w.Header().Set("ETag", `"`+sums.ETag+`"`)
+	}
+	if sums.CRC32C != "" {
+		w.Header().Set("X-Amz-Checksum-Crc32c", sums.CRC32C)
+	}
+	if sums.SHA256 != "" {
+		w.Header().Set("X-Amz-Checksum-Sha256", sums.SHA256)
+	}
+}
+
+// wantSHA256 tells whether an upload asks for a SHA-256, by naming the
+// algorithm or by sending one.
+func wantSHA256(r *http.Request) bool {
+	for _, name := range []string{"X-Amz-Checksum-Algorithm", "X-Amz-Sdk-Checksum-Algorithm"} {
+		if strings.EqualFold(r.Header.Get(name), "SHA256") {
+			return true
+		}
+	}
+	return r.Header.Get("X-Amz-Checksum-Sha256") != "" ||
+		strings.Contains(strings.ToLower(r.Header.Get("X-Amz-Trailer")), "x-amz-checksum-sha256")
+}
+
+var errInvalidDigest = errors.New("the checksum is malformed")
+
+// verifyUpload checks the checksums of an upload against those the client
+// sent as Content-MD5, as x-amz-checksum headers, or as trailers of its body,
+// which are only known once body has been read to the end.
+func verifyUpload(r *http.Request, body io.Reader, sums object.Checksums) error {
+	lookup := func(name string) string {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+		if value := r.Trailer.Get(name); value != "" {
+			return value
+		}
+		if chunked, ok := body.(*awsChunkedReader); ok {
+			return chunked.trailers.Get(name)
+		}
+		return ""
+	}
+	expected := object.Checksums{CRC32C: lookup("X-Amz-Checksum-Crc32c"), SHA256: lookup("X-Amz-Checksum-Sha256")}
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		digest, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(digest) != md5.Size {
+			return errInvalidDigest
+		}
+		expected.ETag = hex.EncodeToString(digest)
+	}
+	return sums.Verify(expected)
+}
+
+func writeXML(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("S3 response encoding failed: %v", err)
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, code int, s3Code, message string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(code)
+		return
+	}
+	writeXML(w, code, s3Error{Code: s3Code, Message: message, Resource: r.URL.Path})
+}
+
+// writeFaultError maps injected gRPC failures onto S3 error codes.
+func writeFaultError(w http.ResponseWriter, r *http.Request, err error) {
+	if status.Code(err) == codes.ResourceExhausted {
+		writeS3Error(w, r, http.StatusServiceUnavailable, "SlowDown", "Please reduce your request rate.")
+		return
+	}
+	writeS3Error(w, r, http.StatusServiceUnavailable, "ServiceUnavailable", status.Convert(err).Message())
+}
+
+// writeStoreError maps Store errors onto S3 error codes.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	code, s3Code, message := s3StoreError(err)
+	writeS3Error(w, r, code, s3Code, message)
+}
+
+// s3StoreError returns the HTTP status, S3 error code and message of a Store
+// error.
+func s3StoreError(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound, "NoSuchKey", "The specified key does not exist."
+	case errors.Is(err, errBucketNotFound):
+		return http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."
+	case errors.Is(err, errInvalidName):
+		return http.StatusBadRequest, "InvalidArgument", err.Error()
+	case errors.Is(err, errInvalidRange):
+		return http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable"
+	case errors.Is(err, errNoSuchUpload):
+		return http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist."
+	case errors.Is(err, errUploadInProgress):
+		return http.StatusConflict, "OperationAborted", "A conflicting operation is currently in progress against this upload. Please try again."
+	case errors.Is(err, errInvalidPart):
+		return http.StatusBadRequest, "InvalidPart", err.Error()
+	case errors.Is(err, errInvalidPartOrder):
+		return http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order."
+	case errors.Is(err, errEntityTooSmall):
+		return http.StatusBadRequest, "EntityTooSmall", err.Error()
+	case errors.Is(err, errInvalidStorageClass):
+		return http.StatusBadRequest, "InvalidStorageClass", "The storage class you specified is not valid."
+	case errors.Is(err, errMetadataTooLarge):
+		return http.StatusBadRequest, "MetadataTooLarge", err.Error()
+	case errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold"
+	case errors.Is(err, errInvalidDigest):
+		return http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified was invalid."
+	case errors.Is(err, object.ErrBadDigest):
+		return http.StatusBadRequest, "BadDigest", err.Error()
+	case errors.Is(err, errCopyToItself):
+		return http.StatusBadRequest, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata."
+	default:
+		return http.StatusInternalServerError, "InternalError", err.Error()
+	}
+}
+
+// faultReader passes an upload body through a fault.
+type faultReader struct {
+	io.Reader
+	fault  *faultRule
+	offset int64
+}
+
+func (r *faultReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	data, faultErr := r.fault.apply(b[:n], r.offset)
+	r.offset += int64(n)
+	copy(b, data)
+	if faultErr != nil {
+		return len(data), faultErr
+	}
+	return len(data), err
+}
+
+// faultWriter passes a download body through a fault.
+type faultWriter struct {
+	io.Writer
+	fault  *faultRule
+	offset int64
+}
+
+func (w *faultWriter) Write(b []byte) (int, error) {
+	data, faultErr := w.fault.apply(b, w.offset)
+	w.offset += int64(len(b))
+	if _, err := w.Writer.Write(data); err != nil {
+		return 0, err
+	}
+	if faultErr != nil {
+		return len(data), faultErr
+	}
+	return len(b), nil
+}
+
+// awsChunkedReader decodes the aws-chunked encoding used by SigV4 streaming
+// uploads. Chunk signatures are skipped, not verified, and the trailers,
+// which carry the checksums of the payload, are kept once the body is read.
+type awsChunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+	trailers  http.Header
+}
+
+func newAWSChunkedReader(r io.Reader) *awsChunkedReader {
+	return &awsChunkedReader{r: bufio.NewReader(r)}
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("malformed aws-chunked header %q", strings.TrimSpace(line))
+		}
+		if size == 0 {
+			// Read the trailers up to the closing empty line.
+			c.done = true
+			c.trailers = make(http.Header)
+			for {
+				line, err := c.r.ReadString('\n')
+				if err != nil || strings.TrimSpace(line) == "" {
+					return 0, io.EOF
+				}
+				if name, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+					c.trailers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+				}
+			}
+		}
+		c.remaining = size
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	if c.remaining == 0 && err == nil {
+		// Every chunk ends with CRLF.
+		_, err = c.r.ReadString('\n')
+	}
+	return n, err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		size           int64
+		offset, length int64
+		ranged         bool
+		err            error
+	}{
+		{header: "", size: 10},
+		{header: "bytes=0-4", size: 10, offset: 0, length: 5, ranged: true},
+		{header: "bytes=5-", size: 10, offset: 5, length: 5, ranged: true},
+		{header: "bytes=9-9", size: 10, offset: 9, length: 1, ranged: true},
+		// The end is clamped later on, by byteRange.
+		{header: "bytes=8-20", size: 10, offset: 8, length: 13, ranged: true},
+		{header: "bytes=-3", size: 10, offset: 7, length: 3, ranged: true},
+		{header: "bytes=-20", size: 10, offset: 0, length: 10, ranged: true},
+		{header: "bytes=10-", size: 10, err: errInvalidRange},
+		{header: "bytes=4-2", size: 10, err: errInvalidRange},
+		{header: "bytes=-0", size: 10, err: errInvalidRange},
+		{header: "bytes=-5", size: 0, err: errInvalidRange},
+		{header: "bytes=x-4", size: 10, err: errInvalidRange},
+		// S3 ignores multiple ranges and other units.
+		{header: "bytes=0-1,3-4", size: 10},
+		{header: "items=0-4", size: 10},
+		{header: "bytes=4", size: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			offset, length, ranged, err := parseRange(test.header, test.size)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("parseRange(%q, %d) error = %v, want %v", test.header, test.size, err, test.err)
+			}
+			if offset != test.offset || length != test.length || ranged != test.ranged {
+				t.Errorf("parseRange(%q, %d) = %d, %d, %t, want %d, %d, %t",
+					test.header, test.size, offset, length, ranged, test.offset, test.length, test.ranged)
+			}
+		})
+	}
+}
+
+func TestAWSChunkedReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		data     string
+		trailers map[string]string
+		err      bool
+	}{
+		{
+			name: "empty",
+			body: "0\r\n\r\n",
+		},
+		{
+			name: "signed chunks",
+			body: "5;chunk-signature=aaaa\r\nhello\r\n6;chunk-signature=bbbb\r\n world\r\n0;chunk-signature=cccc\r\n\r\n",
+			data: "hello world",
+		},
+		{
+			name:     "trailers",
+			body:     "3\r\nabc\r\n0\r\nx-amz-checksum-crc32c:NSRBwg==\r\nx-amz-trailer-signature: dddd\r\n\r\n",
+			data:     "abc",
+			trailers: map[string]string{"X-Amz-Checksum-Crc32c": "NSRBwg==", "X-Amz-Trailer-Signature": "dddd"},
+		},
+		{
+			name: "chunk larger than a read",
+			body: "1000\r\n" + strings.Repeat("x", 0x1000) + "\r\n0\r\n\r\n",
+			data: strings.Repeat("x", 0x1000),
+		},
+		{
+			name: "truncated chunk",
+			body: "5\r\nhel",
+			err:  true,
+		},
+		{
+			name: "missing final chunk",
+			body: "5\r\nhello\r\n",
+			err:  true,
+		},
+		{
+			name: "malformed size",
+			body: "zz\r\nhello\r\n0\r\n\r\n",
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newAWSChunkedReader(strings.NewReader(test.body))
+			data, err := io.ReadAll(r)
+			if (err != nil) != test.err {
+				t.Fatalf("read error = %v, want error %t", err, test.err)
+			}
+			if test.err {
+				return
+			}
+			if string(data) != test.data {
+				t.Errorf("read %q, want %q", data, test.data)
+			}
+			if len(r.trailers) != len(test.trailers) {
+				t.Errorf("got trailers %v, want %v", r.trailers, test.trailers)
+			}
+			for name, value := range test.trailers {
+				if got := r.trailers.Get(name); got != value {
+					t.Errorf("trailer %s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+// TestS3MissingBucket checks that, like S3, writes to a bucket that was never
+// created fail with NoSuchBucket, as listing it does.
+func TestS3MissingBucket(t *testing.T) {
+	model, err := latency.Parse([]byte(`{"operations": {"GET": {"c": 0}, "PUT": {"c": 0}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &s3Handler{server: newServer(newMemoryStore(), model, newBandwidth(0, 0, 0))}
+	do := func(method, target string, header map[string]string) (int, string) {
+		r := httptest.NewRequest(method, target, strings.NewReader("data"))
+		for name, value := range header {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	tests := []struct {
+		name, method, target string
+		header               map[string]string
+	}{
+		{name: "put", method: http.MethodPut, target: "/b/k"},
+		{name: "list", method: http.MethodGet, target: "/b?list-type=2"},
+		{name: "copy", method: http.MethodPut, target: "/b/copy", header: map[string]string{"X-Amz-Copy-Source": "/other/k"}},
+		{name: "multipart", method: http.MethodPost, target: "/b/k?uploads"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code, body := do(test.method, test.target, test.header); code != http.StatusNotFound || !strings.Contains(body, "NoSuchBucket") {
+				t.Errorf("%s %s = %d %s, want 404 NoSuchBucket", test.method, test.target, code, body)
+			}
+		})
+	}
+
+	for _, target := range []string{"/b", "/other", "/other/k", "/b/k"} {
+		if code, body := do(http.MethodPut, target, nil); code != http.StatusOK {
+			t.Fatalf("PUT %s = %d %s", target, code, body)
+		}
+	}
+	for _, test := range tests {
+		if code, body := do(test.method, test.target, test.header); code != http.StatusOK {
+			t.Errorf("%s %s once the bucket exists = %d %s", test.method, test.target, code, body)
+		}
+	}
+}
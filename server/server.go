@@ -1,94 +1,572 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"flag"
 	"io"
-	"math"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	object "github.com/JooyoungPark73/mocks3/object"
 	pb "github.com/JooyoungPark73/mocks3/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type server struct {
 	pb.UnimplementedFileServiceServer
+
+	store     Store
+	model     atomic.Pointer[latency.Model]
+	bandwidth *bandwidth
+	faults    faults
+	counters  *counters
+	uploads   multipartUploads
 }
 
-var (
-	port      = flag.String("port", "30000", "the port to listen on")
-	verbosity = flag.String("verbosity", "info", "Logging verbosity - choose from [info, debug, trace]")
-	buffer    = make([]byte, 2*1024*1024) // 2MB
-)
+func newServer(store Store, model *latency.Model, bw *bandwidth) *server {
+	s := &server{store: store, bandwidth: bw, counters: newCounters()}
+	s.model.Store(model)
+	return s
+}
 
-func (s *server) GetTimeToSleep(commType string, fileSize int64) time.Duration {
-	// Sourced from: https://github.com/vhive-serverless/MockS3/blob/main/mocks3/mock_io_functions.py
-	// [  0.12018868   1.11999534 111.24820149]
-	// a * np.exp(b * np.log10(x_point)) + c
+func checkObjectRef(ref *pb.ObjectRef) error {
+	if ref.GetBucket() == "" || ref.GetKey() == "" {
+		return status.Error(codes.InvalidArgument, "object requires both bucket and key")
+	}
+	return checkBucket(ref.GetBucket())
+}
+
+func checkBucket(bucket string) error {
+	if bucket == "" {
+		return status.Error(codes.InvalidArgument, "bucket is required")
+	}
+	if bucket == multipartBucket {
+		return status.Errorf(codes.InvalidArgument, "bucket %s is reserved", multipartBucket)
+	}
+	return nil
+}
+
+// storeError maps Store errors onto gRPC status codes.
+func storeError(ref *pb.ObjectRef, err error) error {
+	if errors.Is(err, errNotFound) {
+		return status.Errorf(codes.NotFound, "object %s does not exist", objectName(ref.GetBucket(), ref.GetKey()))
+	} else if errors.Is(err, errBucketNotFound) {
+		return status.Errorf(codes.NotFound, "bucket %s does not exist", ref.GetBucket())
+	} else if errors.Is(err, errCopyToItself) {
+		return status.Errorf(codes.InvalidArgument, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errInvalidToken) {
+		return status.Errorf(codes.InvalidArgument, "bucket %s: %v", ref.GetBucket(), err)
+	} else if errors.Is(err, errInvalidName) {
+		return status.Errorf(codes.InvalidArgument, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errPreconditionFailed) {
+		return status.Errorf(codes.FailedPrecondition, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, object.ErrBadDigest) {
+		return status.Errorf(codes.DataLoss, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errInvalidRange) {
+		return status.Errorf(codes.OutOfRange, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errNoSuchUpload) {
+		return status.Errorf(codes.NotFound, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errUploadInProgress) {
+		return status.Errorf(codes.Aborted, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	} else if errors.Is(err, errInvalidPart) || errors.Is(err, errInvalidPartOrder) || errors.Is(err, errEntityTooSmall) ||
+		errors.Is(err, errInvalidStorageClass) || errors.Is(err, errMetadataTooLarge) {
+		return status.Errorf(codes.InvalidArgument, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+	}
+	return status.Errorf(codes.Internal, "object %s: %v", objectName(ref.GetBucket(), ref.GetKey()), err)
+}
 
-	latency := 120.18868*math.Exp(1.11999534*math.Log10(float64(fileSize))) + 111248.20149
+// uploadMeta reads the metadata an upload sets.
+func uploadMeta(md *pb.ObjectMetadata) (ObjectMeta, error) {
+	return newObjectMeta(md.GetContentType(), md.GetUserMetadata(), md.GetStorageClass())
+}
 
-	if commType == "GET" {
-		latency = latency * 0.33
-	} else if commType == "PUT" {
-		latency = latency * 0.67
-	} else {
-		log.Panic("Invalid communication type")
+func objectMetadata(info ObjectInfo) *pb.ObjectMetadata {
+	return &pb.ObjectMetadata{
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: timestamppb.New(info.LastModified),
+		UserMetadata: info.UserMetadata,
+		StorageClass: info.StorageClass,
+		Checksums:    info.Checksums.Proto(),
 	}
-	sleepTime := time.Duration(latency) * time.Microsecond
-	return sleepTime
+}
+
+// Trailer keys of GetFile, named after the S3 headers.
+const (
+	trailerETag   = "etag"
+	trailerCRC32C = "x-amz-checksum-crc32c"
+	trailerSHA256 = "x-amz-checksum-sha256"
+)
+
+// checksumTrailer lists the checksums GetFile ends with.
+func checksumTrailer(sums object.Checksums) metadata.MD {
+	md := metadata.Pairs(trailerCRC32C, sums.CRC32C)
+	if sums.ETag != "" {
+		md.Set(trailerETag, sums.ETag)
+	}
+	if sums.SHA256 != "" {
+		md.Set(trailerSHA256, sums.SHA256)
+	}
+	return md
+}
+
+var (
+	port        = flag.String("port", "30000", "the port to listen on")
+	backend     = flag.String("backend", "memory", "Object storage backend - choose from [memory, disk, discard]")
+	dataDir     = flag.String("data-dir", "/tmp/mocks3", "Directory used by the disk backend")
+	httpPort    = flag.String("http-port", "", "the port for the S3-compatible HTTP API, disabled when empty")
+	latencyMode = flag.String("latency", "client", "Where the gRPC latency model is enforced - choose from [client, server]")
+	modelPath   = flag.String("latency-model", "", "Latency model JSON file, defaults to $MOCKS3_LATENCY_MODEL or the built-in curve")
+	streamBW    = flag.Float64("stream-bandwidth", 0, "Bandwidth cap of each transfer in MiB/s, 0 for unlimited")
+	clientBW    = flag.Float64("client-bandwidth", 0, "Bandwidth cap of each client host in MiB/s, 0 for unlimited")
+	globalBW    = flag.Float64("global-bandwidth", 0, "Bandwidth cap of the whole server in MiB/s, 0 for unlimited")
+	faultsPath  = flag.String("faults", "", "Fault injection rules JSON file, reloaded on SIGHUP")
+	verbosity   = flag.String("verbosity", "info", "Logging verbosity - choose from [info, debug, trace]")
+	buffer      = make([]byte, 2*1024*1024) // 2MB
+)
+
+func (s *server) GetTimeToSleep(commType string, fileSize int64) time.Duration {
+	return s.model.Load().GetTimeToSleep(commType, fileSize)
 }
 
 func (s *server) GetFile(req *pb.FileSize, stream pb.FileService_GetFileServer) error {
-	size := req.GetSize()
+	if req.GetObject() != nil {
+		return s.getObject(req, stream)
+	}
+	// A range of the synthetic object is just as many synthetic bytes.
+	_, size, err := byteRange(req.GetOffset(), req.GetLength(), req.GetSize())
+	if err != nil {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	pace := s.grpcPacer("GET")
+	limit := s.bandwidth.stream(peerHost(stream.Context()))
+	defer limit.close()
 
 	log.Debugf("GET: %d Bytes", size)
 
+	fault := s.pickFault("GET", "", size)
+	if err := fault.begin(); err != nil {
+		return err
+	}
+	pace.advance(0)
+	digest := object.NewDigester(false, false)
+	if size == 0 {
+		// Still tell the client the size of the object.
+		if err := stream.Send(&pb.FileBlob{Size: req.GetSize()}); err != nil && err != io.EOF {
+			return err
+		}
+	}
 	for remaining := size; remaining > 0; remaining -= int64(len(buffer)) {
 		chunk := buffer
 		if remaining < int64(len(buffer)) {
 			chunk = buffer[:remaining]
 		}
-		if err := stream.Send(&pb.FileBlob{Blob: chunk}); err != nil {
+		pace.advance(len(chunk))
+		limit.wait(len(chunk))
+		digest.Write(chunk)
+		blob := &pb.FileBlob{}
+		if remaining == size {
+			blob.Size = req.GetSize()
+		}
+		var faultErr error
+		blob.Blob, faultErr = fault.apply(chunk, size-remaining)
+		if err := stream.Send(blob); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
+		if faultErr != nil {
+			return faultErr
+		}
 	}
 
+	stream.SetTrailer(checksumTrailer(digest.Sum()))
 	return nil
 }
 
+// getObject ends with the CRC32C of the bytes sent, computed before any
+// fault is applied so that the client can tell. Reads of a whole object also
+// end with its stored ETag and SHA-256. A conditional read of an unchanged
+// object only gets its metadata, paced like a HEAD.
+func (s *server) getObject(req *pb.FileSize, stream pb.FileService_GetFileServer) error {
+	ref := req.GetObject()
+	pace := s.grpcPacer("GET")
+	if err := checkObjectRef(ref); err != nil {
+		return err
+	}
+	if info, err := checkRead(s.store, ref.GetBucket(), ref.GetKey(), object.PreconditionsFrom(req.GetPreconditions())); errors.Is(err, errNotModified) {
+		log.Debugf("GET: %s not modified", objectName(ref.GetBucket(), ref.GetKey()))
+		s.grpcPacer("HEAD").advance(0)
+		return stream.Send(&pb.FileBlob{Size: info.Size, Metadata: objectMetadata(info), NotModified: true})
+	} else if err != nil {
+		return storeError(ref, err)
+	}
+	r, info, first, size, err := openRange(s.store, ref.GetBucket(), ref.GetKey(), req.GetOffset(), req.GetLength())
+	if err != nil {
+		return storeError(ref, err)
+	}
+	defer r.Close()
+	limit := s.bandwidth.stream(peerHost(stream.Context()))
+	defer limit.close()
+
+	log.Debugf("GET: %s, %d of %d Bytes from %d", objectName(ref.GetBucket(), ref.GetKey()), size, info.Size, first)
+
+	fault := s.pickFault("GET", ref.GetKey(), size)
+	if err := fault.begin(); err != nil {
+		return err
+	}
+	pace.advance(0)
+	digest := object.NewDigester(false, false)
+	chunk := make([]byte, len(buffer))
+	for offset := int64(0); ; {
+		n, err := io.ReadFull(r, chunk)
+		// The first blob carries the size and metadata, so it is sent even
+		// for an empty object.
+		if n > 0 || (offset == 0 && err == io.EOF) {
+			pace.advance(n)
+			limit.wait(n)
+			digest.Write(chunk[:n])
+			blob := &pb.FileBlob{}
+			if offset == 0 {
+				blob.Size = info.Size
+				blob.Metadata = objectMetadata(info)
+			}
+			var faultErr error
+			blob.Blob, faultErr = fault.apply(chunk[:n], offset)
+			offset += int64(n)
+			if err := stream.Send(blob); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			if faultErr != nil {
+				return faultErr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return storeError(ref, err)
+		}
+	}
+
+	sums := digest.Sum()
+	if size == info.Size {
+		sums.ETag, sums.SHA256 = info.ETag, info.SHA256
+	}
+	stream.SetTrailer(checksumTrailer(sums))
+	return nil
+}
+
+// PutFile computes the checksums of the bytes it receives, the MD5 ETag only
+// for objects, and fails with DATA_LOSS without storing anything when they do
+// not match those the client sent.
 func (s *server) PutFile(stream pb.FileService_PutFileServer) error {
+	pace := s.grpcPacer("PUT")
+	limit := s.bandwidth.stream(peerHost(stream.Context()))
+	defer limit.close()
+
+	// The first chunk names the object, if any, and may announce the size.
+	chunk, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	ref := chunk.GetObject()
+	cond := object.PreconditionsFrom(chunk.GetPreconditions())
+	var (
+		w    ObjectWriter
+		meta ObjectMeta
+	)
+	if ref != nil {
+		if err := checkObjectRef(ref); err != nil {
+			return err
+		}
+		var metaErr error
+		if meta, metaErr = uploadMeta(chunk.GetMetadata()); metaErr != nil {
+			return storeError(ref, metaErr)
+		}
+		var createErr error
+		if w, createErr = s.store.Create(ref.GetBucket(), ref.GetKey()); createErr != nil {
+			return storeError(ref, createErr)
+		}
+	}
+	digest := object.NewDigester(ref != nil, chunk.GetChecksumAlgorithm() == pb.ChecksumAlgorithm_SHA256)
+	var dst io.Writer = digest
+	if w != nil {
+		dst = io.MultiWriter(w, digest)
+	}
+	announced := int64(-1)
+	if chunk.GetSize() > 0 {
+		announced = chunk.GetSize()
+	}
+	fault := s.pickFault("PUT", ref.GetKey(), announced)
+	if faultErr := fault.begin(); faultErr != nil {
+		if w != nil {
+			w.Abort()
+		}
+		return faultErr
+	}
+
+	size, expected, err := s.receiveBlobs(stream, chunk, err, ref, dst, fault, pace, limit)
+	if err == nil {
+		meta.Checksums = digest.Sum()
+		err = meta.Verify(expected)
+	}
+	if err != nil {
+		if w != nil {
+			w.Abort()
+		}
+		if errors.Is(err, object.ErrBadDigest) {
+			return status.Errorf(codes.DataLoss, "PUT of %d Bytes: %v", size, err)
+		}
+		return err
+	}
+	if w == nil {
+		log.Debugf("PUT: %d Bytes", size)
+		return stream.SendAndClose(&pb.FileSize{Size: size, Checksums: meta.Checksums.Proto()})
+	}
+	if err := w.Commit(meta, cond); err != nil {
+		return storeError(ref, err)
+	}
+	log.Debugf("PUT: %s, %d Bytes, ETag %s", objectName(ref.GetBucket(), ref.GetKey()), size, meta.ETag)
+	return stream.SendAndClose(&pb.FileSize{Size: size, Checksums: meta.Checksums.Proto()})
+}
+
+func (s *server) HeadFile(ctx context.Context, ref *pb.ObjectRef) (*pb.ObjectMetadata, error) {
+	pace := s.grpcPacer("HEAD")
+	if err := checkObjectRef(ref); err != nil {
+		return nil, err
+	}
+	if err := s.pickFault("HEAD", ref.GetKey(), -1).begin(); err != nil {
+		return nil, err
+	}
+	info, err := s.store.Stat(ref.GetBucket(), ref.GetKey())
+	if err != nil {
+		return nil, storeError(ref, err)
+	}
+	log.Debugf("HEAD: %s, %d Bytes", objectName(ref.GetBucket(), ref.GetKey()), info.Size)
+	pace.advance(0)
+	return objectMetadata(info), nil
+}
+
+// ListFiles lists a page of a bucket. It is paced by the LIST curve at the
+// number of entries returned.
+func (s *server) ListFiles(ctx context.Context, req *pb.ListRequest) (*pb.ListResult, error) {
+	pace := s.grpcPacer("LIST")
+	if err := checkBucket(req.GetBucket()); err != nil {
+		return nil, err
+	}
+	ref := &pb.ObjectRef{Bucket: req.GetBucket()}
+	maxKeys := int(req.GetMaxKeys())
+	if maxKeys < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_keys must not be negative")
+	}
+	if maxKeys == 0 || maxKeys > maxListKeys {
+		maxKeys = maxListKeys
+	}
+	startAfter := req.GetStartAfter()
+	if req.GetContinuationToken() != "" {
+		var err error
+		if startAfter, err = parseContinuationToken(req.GetContinuationToken()); err != nil {
+			return nil, storeError(ref, err)
+		}
+	}
+	if err := s.pickFault("LIST", req.GetPrefix(), -1).begin(); err != nil {
+		return nil, err
+	}
+	objects, prefixes, next, truncated, err := listPage(s.store, req.GetBucket(), req.GetPrefix(), req.GetDelimiter(), startAfter, maxKeys)
+	if err != nil {
+		return nil, storeError(ref, err)
+	}
+
+	result := &pb.ListResult{CommonPrefixes: prefixes}
+	for _, obj := range objects {
+		result.Objects = append(result.Objects, &pb.ListedObject{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: timestamppb.New(obj.LastModified),
+			StorageClass: obj.StorageClass,
+		})
+	}
+	if truncated {
+		result.IsTruncated = true
+		result.NextContinuationToken = continuationToken(next)
+	}
+	log.Debugf("LIST: %s/%s, %d objects and %d prefixes", req.GetBucket(), req.GetPrefix(), len(objects), len(prefixes))
+	pace.advance(len(objects) + len(prefixes))
+	return result, nil
+}
+
+func (s *server) DeleteFile(ctx context.Context, ref *pb.ObjectRef) (*emptypb.Empty, error) {
+	pace := s.grpcPacer("DELETE")
+	if err := checkObjectRef(ref); err != nil {
+		return nil, err
+	}
+	if err := s.pickFault("DELETE", ref.GetKey(), -1).begin(); err != nil {
+		return nil, err
+	}
+	if err := deleteObject(s.store, ref.GetBucket(), ref.GetKey()); err != nil {
+		return nil, storeError(ref, err)
+	}
+	log.Debugf("DELETE: %s", objectName(ref.GetBucket(), ref.GetKey()))
+	pace.advance(0)
+	return &emptypb.Empty{}, nil
+}
+
+// DeleteFiles deletes the keys one by one, a failure only fails its key. It
+// is paced by the BATCH_DELETE curve at the number of keys.
+func (s *server) DeleteFiles(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResult, error) {
+	pace := s.grpcPacer("BATCH_DELETE")
+	if err := checkBucket(req.GetBucket()); err != nil {
+		return nil, err
+	}
+	keys := req.GetKeys()
+	if len(keys) == 0 || len(keys) > maxDeleteKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "a batch delete takes from 1 to %d keys, got %d", maxDeleteKeys, len(keys))
+	}
+	if err := s.pickFault("DELETE", "", -1).begin(); err != nil {
+		return nil, err
+	}
+	result := &pb.DeleteResult{Results: make([]*pb.KeyResult, len(keys))}
+	deleted := 0
+	for i, key := range keys {
+		ref := &pb.ObjectRef{Bucket: req.GetBucket(), Key: key}
+		err := checkObjectRef(ref)
+		if err == nil {
+			if err = deleteObject(s.store, ref.GetBucket(), key); err != nil {
+				err = storeError(ref, err)
+			}
+		}
+		result.Results[i] = &pb.KeyResult{Key: key, Code: int32(status.Code(err))}
+		if err != nil {
+			result.Results[i].Message = status.Convert(err).Message()
+		} else {
+			deleted++
+		}
+	}
+	log.Debugf("DELETE: %d of %d keys of %s", deleted, len(keys), req.GetBucket())
+	pace.advance(len(keys))
+	return result, nil
+}
+
+// CopyFile is paced by the COPY curve at the size of the object.
+func (s *server) CopyFile(ctx context.Context, req *pb.CopyRequest) (*pb.ObjectMetadata, error) {
+	pace := s.grpcPacer("COPY")
+	src, dst := req.GetSource(), req.GetDestination()
+	if err := checkObjectRef(src); err != nil {
+		return nil, err
+	}
+	if err := checkObjectRef(dst); err != nil {
+		return nil, err
+	}
+	var meta *ObjectMeta
+	if req.GetReplaceMetadata() {
+		replaced, err := uploadMeta(req.GetMetadata())
+		if err != nil {
+			return nil, storeError(dst, err)
+		}
+		meta = &replaced
+	}
+	if err := s.pickFault("COPY", dst.GetKey(), -1).begin(); err != nil {
+		return nil, err
+	}
+	info, err := copyObject(s.store, src.GetBucket(), src.GetKey(), dst.GetBucket(), dst.GetKey(), meta)
+	if errors.Is(err, errNotFound) {
+		return nil, storeError(src, err)
+	} else if err != nil {
+		return nil, storeError(dst, err)
+	}
+	log.Debugf("COPY: %s to %s, %d Bytes", objectName(src.GetBucket(), src.GetKey()), objectName(dst.GetBucket(), dst.GetKey()), info.Size)
+	pace.advance(int(info.Size))
+	return objectMetadata(info), nil
+}
+
+// receiveBlobs writes the blobs of an upload to w, starting with the chunk
+// already read along with its error, until the client closes the stream. A
+// nil w drops them. It returns the number of bytes received and the last
+// checksums the client sent.
+func (s *server) receiveBlobs(stream interface{ Recv() (*pb.FileBlob, error) }, chunk *pb.FileBlob, err error, ref *pb.ObjectRef, w io.Writer, fault *faultRule, pace *pacer, limit *limiter) (int64, object.Checksums, error) {
 	size := int64(0)
-	for {
-		chunk, err := stream.Recv()
-		size += int64(len(chunk.GetBlob()))
+	var expected object.Checksums
+	for ; ; chunk, err = stream.Recv() {
+		pace.advance(len(chunk.GetBlob()))
+		limit.wait(len(chunk.GetBlob()))
+		data, faultErr := fault.apply(chunk.GetBlob(), size)
+		size += int64(len(data))
+		if w != nil {
+			if _, writeErr := w.Write(data); writeErr != nil {
+				return size, expected, storeError(ref, writeErr)
+			}
+		}
+		if chunk.GetChecksums() != nil {
+			expected = object.ChecksumsFrom(chunk.GetChecksums())
+		}
+		if faultErr != nil {
+			return size, expected, faultErr
+		}
 		if err == io.EOF {
-			log.Debugf("PUT: %d Bytes", size)
-			return stream.SendAndClose(&pb.FileSize{Size: size})
+			return size, expected, nil
 		}
 		if err != nil {
-			return err
+			return size, expected, err
 		}
 	}
 }
 
-func init() {
-	flag.Parse()
+// pickFault draws the fault for one transfer and counts it when one fires.
+func (s *server) pickFault(commType, key string, size int64) *faultRule {
+	fault := s.faults.pick(commType, key, size)
+	if fault != nil {
+		s.counters.faultInjected()
+	}
+	return fault
+}
+
+func (s *server) reloadFaults(path string) {
+	rules, err := loadFaultRules(path)
+	if err == nil {
+		err = s.faults.set(rules)
+	}
+	if err != nil {
+		log.Errorf("failed to load fault rules from %s: %v", path, err)
+		return
+	}
+	log.Infof("loaded %d fault rules from %s", len(rules), path)
+}
 
+func init() {
 	log.SetFormatter(&log.TextFormatter{
 		TimestampFormat: time.StampMilli,
 		FullTimestamp:   true,
 	})
 	log.SetOutput(os.Stdout)
+	rand.Read(buffer)
+}
 
+func main() {
+	// Flags are parsed here rather than in init so that tests can register
+	// theirs.
+	flag.Parse()
 	switch *verbosity {
 	case "debug":
 		log.SetLevel(log.DebugLevel)
@@ -97,17 +575,51 @@ func init() {
 	default:
 		log.SetLevel(log.InfoLevel)
 	}
-	rand.Read(buffer)
-}
 
-func main() {
 	// maxMsgSize := int(math.Pow(2, 31) + 1024) // 2GB
 	lis, err := net.Listen("tcp", ":"+*port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterFileServiceServer(s, &server{})
+	store, err := newStore(*backend, *dataDir)
+	if err != nil {
+		log.Fatalf("failed to open %s backend: %v", *backend, err)
+	}
+	log.Infof("using %s backend", *backend)
+
+	model, err := latency.Resolve(*modelPath)
+	if err != nil {
+		log.Fatalf("failed to load latency model: %v", err)
+	}
+
+	srv := newServer(store, model, newBandwidth(*streamBW*mib, *clientBW*mib, *globalBW*mib))
+	if *faultsPath != "" {
+		srv.reloadFaults(*faultsPath)
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				srv.reloadFaults(*faultsPath)
+			}
+		}()
+	}
+	if *httpPort != "" {
+		go func() {
+			log.Infof("S3 API listening at :%s", *httpPort)
+			if err := http.ListenAndServe(":"+*httpPort, &s3Handler{server: srv}); err != nil {
+				log.Fatalf("failed to serve S3 API: %v", err)
+			}
+		}()
+	}
+
+	s := grpc.NewServer(
+		grpc.ChainStreamInterceptor(srv.counters.streamInterceptor, pacedStreamInterceptor),
+		grpc.ChainUnaryInterceptor(srv.counters.unaryInterceptor, pacedUnaryInterceptor),
+	)
+	pb.RegisterFileServiceServer(s, srv)
+	pb.RegisterAdminServiceServer(s, &adminServer{server: srv})
+	// Reflection lets grpcurl drive the admin service without the protos.
+	reflection.Register(s)
 	log.Infof("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
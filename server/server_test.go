@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
+	pb "github.com/JooyoungPark73/mocks3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestClient serves a FileService over an in-memory connection and
+// returns a client of it.
+func newTestClient(t *testing.T, store Store) pb.FileServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterFileServiceServer(s, newServer(store, latency.DefaultModel(), newBandwidth(0, 0, 0)))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewFileServiceClient(conn)
+}
+
+// putFile uploads data in first, followed by last when it is not nil.
+func putFile(client pb.FileServiceClient, first *pb.FileBlob, data []byte, last *pb.FileBlob) (*pb.FileSize, error) {
+	stream, err := client.PutFile(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	first.Blob = data
+	if err := stream.Send(first); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if last != nil {
+		if err := stream.Send(last); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func mustPutFile(t *testing.T, client pb.FileServiceClient, bucket, key string, data []byte) *pb.FileSize {
+	t.Helper()
+	res, err := putFile(client, &pb.FileBlob{Object: &pb.ObjectRef{Bucket: bucket, Key: key}}, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// getFile reads what GetFile sends: the bytes, the first blob and the
+// trailer.
+func getFile(client pb.FileServiceClient, req *pb.FileSize) ([]byte, *pb.FileBlob, metadata.MD, error) {
+	stream, err := client.GetFile(context.Background(), req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		data  bytes.Buffer
+		first *pb.FileBlob
+	)
+	for {
+		blob, err := stream.Recv()
+		if err == io.EOF {
+			return data.Bytes(), first, stream.Trailer(), nil
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if first == nil {
+			first = blob
+		}
+		data.Write(blob.GetBlob())
+	}
+}
+
+func TestGetFileRange(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	data := []byte("0123456789")
+	mustPutFile(t, client, "b", "k", data)
+	ref := &pb.ObjectRef{Bucket: "b", Key: "k"}
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+		code           codes.Code
+	}{
+		{name: "whole object", want: "0123456789"},
+		{name: "range", offset: 2, length: 3, want: "234"},
+		{name: "to the end", offset: 7, want: "789"},
+		{name: "past the end", offset: 8, length: 5, want: "89"},
+		{name: "offset past the end", offset: 10, length: 1, code: codes.OutOfRange},
+		{name: "negative offset", offset: -1, code: codes.OutOfRange},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, first, _, err := getFile(client, &pb.FileSize{Object: ref, Offset: test.offset, Length: test.length})
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("GetFile code = %v (%v), want %v", code, err, test.code)
+			}
+			if err != nil {
+				return
+			}
+			if string(got) != test.want {
+				t.Errorf("GetFile read %q, want %q", got, test.want)
+			}
+			// The first blob has the size of the whole object.
+			if first.GetSize() != int64(len(data)) {
+				t.Errorf("GetFile size = %d, want %d", first.GetSize(), len(data))
+			}
+		})
+	}
+
+	// Synthetic reads send as many bytes as the range holds.
+	got, first, _, err := getFile(client, &pb.FileSize{Size: 100, Offset: 90, Length: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 10 || first.GetSize() != 100 {
+		t.Errorf("synthetic GetFile read %d Bytes of %d, want 10 of 100", len(got), first.GetSize())
+	}
+	if _, _, _, err := getFile(client, &pb.FileSize{Object: &pb.ObjectRef{Bucket: "b", Key: "missing"}}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetFile of a missing object code = %v, want %v", status.Code(err), codes.NotFound)
+	}
+}
+
+// TestListFiles pages through a bucket with continuation tokens.
+func TestListFiles(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	ctx := context.Background()
+	for _, key := range []string{"a", "b/1", "b/2", "c", "d/1", "e"} {
+		mustPutFile(t, client, "b", key, []byte(key))
+	}
+	var (
+		entries []string
+		token   string
+	)
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatal("listing does not end")
+		}
+		res, err := client.ListFiles(ctx, &pb.ListRequest{Bucket: "b", Delimiter: "/", MaxKeys: 2, ContinuationToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(res.GetObjects()) + len(res.GetCommonPrefixes()); n > 2 {
+			t.Fatalf("page of %d entries, want at most 2", n)
+		}
+		for _, obj := range res.GetObjects() {
+			if obj.GetSize() != int64(len(obj.GetKey())) || obj.GetStorageClass() != "STANDARD" {
+				t.Errorf("listed %v, want %d Bytes in STANDARD", obj, len(obj.GetKey()))
+			}
+			entries = append(entries, obj.GetKey())
+		}
+		entries = append(entries, res.GetCommonPrefixes()...)
+		if !res.GetIsTruncated() {
+			break
+		}
+		token = res.GetNextContinuationToken()
+	}
+	if got, want := strings.Join(entries, " "), "a b/ c d/ e"; got != want {
+		t.Errorf("listed %s, want %s", got, want)
+	}
+
+	res, err := client.ListFiles(ctx, &pb.ListRequest{Bucket: "b", Prefix: "b/", StartAfter: "b/1"})
+	if err != nil || len(res.GetObjects()) != 1 || res.GetObjects()[0].GetKey() != "b/2" || res.GetIsTruncated() {
+		t.Errorf("ListFiles(b/ after b/1) = %v, %v, want b/2", res, err)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.ListRequest
+		code codes.Code
+	}{
+		{name: "missing bucket", req: &pb.ListRequest{Bucket: "missing"}, code: codes.NotFound},
+		{name: "no bucket", req: &pb.ListRequest{}, code: codes.InvalidArgument},
+		{name: "reserved bucket", req: &pb.ListRequest{Bucket: multipartBucket}, code: codes.InvalidArgument},
+		{name: "negative max keys", req: &pb.ListRequest{Bucket: "b", MaxKeys: -1}, code: codes.InvalidArgument},
+		{name: "malformed token", req: &pb.ListRequest{Bucket: "b", ContinuationToken: "not base64!"}, code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := client.ListFiles(ctx, test.req); status.Code(err) != test.code {
+				t.Errorf("ListFiles code = %v (%v), want %v", status.Code(err), err, test.code)
+			}
+		})
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	ctx := context.Background()
+	src, dst := &pb.ObjectRef{Bucket: "b", Key: "src"}, &pb.ObjectRef{Bucket: "b", Key: "dst"}
+	if _, err := putFile(client, &pb.FileBlob{Object: src, Metadata: &pb.ObjectMetadata{ContentType: "text/plain", UserMetadata: map[string]string{"owner": "me"}}}, []byte("data"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := client.CopyFile(ctx, &pb.CopyRequest{Source: src, Destination: dst})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.GetSize() != 4 || md.GetContentType() != "text/plain" || md.GetUserMetadata()["owner"] != "me" {
+		t.Errorf("copy = %v, want the metadata of the source", md)
+	}
+	if data, _, _, err := getFile(client, &pb.FileSize{Object: dst}); err != nil || string(data) != "data" {
+		t.Errorf("read %q, %v from the copy, want data", data, err)
+	}
+
+	replaced := &pb.CopyRequest{Source: src, Destination: src, ReplaceMetadata: true, Metadata: &pb.ObjectMetadata{ContentType: "application/json"}}
+	if md, err := client.CopyFile(ctx, replaced); err != nil || md.GetContentType() != "application/json" || len(md.GetUserMetadata()) != 0 {
+		t.Errorf("copy onto itself replacing the metadata = %v, %v, want only application/json", md, err)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.CopyRequest
+		code codes.Code
+	}{
+		{name: "onto itself", req: &pb.CopyRequest{Source: src, Destination: src}, code: codes.InvalidArgument},
+		{name: "missing source", req: &pb.CopyRequest{Source: &pb.ObjectRef{Bucket: "b", Key: "missing"}, Destination: dst}, code: codes.NotFound},
+		{name: "no destination", req: &pb.CopyRequest{Source: src}, code: codes.InvalidArgument},
+		{name: "bad storage class", req: &pb.CopyRequest{Source: src, Destination: dst, ReplaceMetadata: true, Metadata: &pb.ObjectMetadata{StorageClass: "COLD"}}, code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := client.CopyFile(ctx, test.req); status.Code(err) != test.code {
+				t.Errorf("CopyFile code = %v (%v), want %v", status.Code(err), err, test.code)
+			}
+		})
+	}
+}
+
+func TestDeleteFiles(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		mustPutFile(t, client, "b", key, []byte(key))
+	}
+
+	if _, err := client.DeleteFile(ctx, &pb.ObjectRef{Bucket: "b", Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	// Like S3, deleting what does not exist succeeds.
+	if _, err := client.DeleteFile(ctx, &pb.ObjectRef{Bucket: "b", Key: "c"}); err != nil {
+		t.Errorf("deleting twice: %v", err)
+	}
+
+	res, err := client.DeleteFiles(ctx, &pb.DeleteRequest{Bucket: "b", Keys: []string{"b", "missing", "", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		key  string
+		code codes.Code
+	}{{"b", codes.OK}, {"missing", codes.OK}, {"", codes.InvalidArgument}, {"a", codes.OK}}
+	if len(res.GetResults()) != len(want) {
+		t.Fatalf("%d results, want %d", len(res.GetResults()), len(want))
+	}
+	for i, result := range res.GetResults() {
+		if result.GetKey() != want[i].key || codes.Code(result.GetCode()) != want[i].code {
+			t.Errorf("result %d = %s %v, want %s %v", i, result.GetKey(), codes.Code(result.GetCode()), want[i].key, want[i].code)
+		}
+	}
+	if list, err := client.ListFiles(ctx, &pb.ListRequest{Bucket: "b"}); err != nil || len(list.GetObjects()) != 0 {
+		t.Errorf("listed %v, %v after deleting everything", list.GetObjects(), err)
+	}
+
+	if _, err := client.DeleteFiles(ctx, &pb.DeleteRequest{Bucket: "b"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("deleting no keys: code = %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+	if _, err := client.DeleteFiles(ctx, &pb.DeleteRequest{Bucket: "b", Keys: make([]string, maxDeleteKeys+1)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("deleting %d keys: code = %v, want %v", maxDeleteKeys+1, status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	data := []byte("hello, world")
+	ref := &pb.ObjectRef{Bucket: "b", Key: "k"}
+	md5Sum := md5.Sum(data)
+	sha := sha256.Sum256(data)
+	crc := binary.BigEndian.AppendUint32(nil, crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	want := &pb.Checksums{
+		Etag:   hex.EncodeToString(md5Sum[:]),
+		Crc32C: base64.StdEncoding.EncodeToString(crc),
+		Sha256: base64.StdEncoding.EncodeToString(sha[:]),
+	}
+
+	res, err := putFile(client, &pb.FileBlob{Object: ref, ChecksumAlgorithm: pb.ChecksumAlgorithm_SHA256}, data, &pb.FileBlob{Checksums: want})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(res.GetChecksums(), want) {
+		t.Errorf("PutFile checksums = %v, want %v", res.GetChecksums(), want)
+	}
+
+	_, first, trailer, err := getFile(client, &pb.FileSize{Object: ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(first.GetMetadata().GetChecksums(), want) {
+		t.Errorf("GetFile metadata checksums = %v, want %v", first.GetMetadata().GetChecksums(), want)
+	}
+	for key, value := range map[string]string{trailerETag: want.Etag, trailerCRC32C: want.Crc32C, trailerSHA256: want.Sha256} {
+		if got := trailer.Get(key); len(got) != 1 || got[0] != value {
+			t.Errorf("trailer %s = %q, want %s", key, got, value)
+		}
+	}
+
+	// A range only gets the CRC32C of the bytes sent.
+	_, _, trailer, err = getFile(client, &pb.FileSize{Object: ref, Offset: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangeCRC := binary.BigEndian.AppendUint32(nil, crc32.Checksum(data[7:], crc32.MakeTable(crc32.Castagnoli)))
+	if got := trailer.Get(trailerCRC32C); len(got) != 1 || got[0] != base64.StdEncoding.EncodeToString(rangeCRC) {
+		t.Errorf("range trailer %s = %q, want the CRC32C of the range", trailerCRC32C, got)
+	}
+	if got := trailer.Get(trailerETag); len(got) != 0 {
+		t.Errorf("range trailer %s = %q, want none", trailerETag, got)
+	}
+
+	// Uploads whose bytes do not match their checksums store nothing.
+	for name, sums := range map[string]*pb.Checksums{
+		"etag":   {Etag: hex.EncodeToString(make([]byte, md5.Size))},
+		"crc32c": {Crc32C: "AAAAAA=="},
+		"sha256": {Sha256: base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bad := &pb.ObjectRef{Bucket: "b", Key: "bad-" + name}
+			_, err := putFile(client, &pb.FileBlob{Object: bad, ChecksumAlgorithm: pb.ChecksumAlgorithm_SHA256}, data, &pb.FileBlob{Checksums: sums})
+			if status.Code(err) != codes.DataLoss {
+				t.Errorf("PutFile with a bad %s: code = %v (%v), want %v", name, status.Code(err), err, codes.DataLoss)
+			}
+			if _, _, _, err := getFile(client, &pb.FileSize{Object: bad}); status.Code(err) != codes.NotFound {
+				t.Errorf("GetFile after a bad upload: code = %v, want %v", status.Code(err), codes.NotFound)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	client := newTestClient(t, newMemoryStore())
+	ref := &pb.ObjectRef{Bucket: "b", Key: "k"}
+
+	// Of concurrent uploads that only create the object, exactly one wins.
+	const uploaders = 8
+	codesSeen := make(chan codes.Code, uploaders)
+	for i := 0; i < uploaders; i++ {
+		go func(i int) {
+			_, err := putFile(client, &pb.FileBlob{Object: ref, Preconditions: &pb.Preconditions{IfNoneMatch: "*"}}, []byte{byte(i)}, nil)
+			codesSeen <- status.Code(err)
+		}(i)
+	}
+	created := 0
+	for i := 0; i < uploaders; i++ {
+		switch code := <-codesSeen; code {
+		case codes.OK:
+			created++
+		case codes.FailedPrecondition:
+		default:
+			t.Errorf("conditional PutFile code = %v", code)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d uploads created the object, want 1", created)
+	}
+
+	etag := mustPutFile(t, client, "b", "k", []byte("v1")).GetChecksums().GetEtag()
+	if _, err := putFile(client, &pb.FileBlob{Object: ref, Preconditions: &pb.Preconditions{IfMatch: "other"}}, []byte("v2"), nil); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("PutFile with a stale If-Match: code = %v, want %v", status.Code(err), codes.FailedPrecondition)
+	}
+	if _, err := putFile(client, &pb.FileBlob{Object: ref, Preconditions: &pb.Preconditions{IfMatch: `"` + etag + `"`}}, []byte("v2"), nil); err != nil {
+		t.Errorf("PutFile with a matching If-Match: %v", err)
+	}
+
+	data, first, _, err := getFile(client, &pb.FileSize{Object: ref})
+	if err != nil || string(data) != "v2" {
+		t.Fatalf("GetFile = %q, %v, want v2", data, err)
+	}
+	etag = first.GetMetadata().GetChecksums().GetEtag()
+
+	tests := []struct {
+		name        string
+		cond        *pb.Preconditions
+		code        codes.Code
+		notModified bool
+	}{
+		{name: "if-match", cond: &pb.Preconditions{IfMatch: etag}},
+		{name: "stale if-match", cond: &pb.Preconditions{IfMatch: "other"}, code: codes.FailedPrecondition},
+		{name: "if-none-match", cond: &pb.Preconditions{IfNoneMatch: etag}, notModified: true},
+		{name: "other if-none-match", cond: &pb.Preconditions{IfNoneMatch: "other"}},
+		{name: "if-modified-since", cond: &pb.Preconditions{IfModifiedSince: timestamppb.New(time.Now().Add(time.Hour))}, notModified: true},
+		{name: "if-unmodified-since", cond: &pb.Preconditions{IfUnmodifiedSince: timestamppb.New(time.Now().Add(-time.Hour))}, code: codes.FailedPrecondition},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, first, _, err := getFile(client, &pb.FileSize{Object: ref, Preconditions: test.cond})
+			if status.Code(err) != test.code {
+				t.Fatalf("GetFile code = %v (%v), want %v", status.Code(err), err, test.code)
+			}
+			if err != nil {
+				return
+			}
+			if first.GetNotModified() != test.notModified {
+				t.Errorf("not modified = %t, want %t", first.GetNotModified(), test.notModified)
+			}
+			if want := "v2"; test.notModified && len(data) != 0 {
+				t.Errorf("not modified read sent %q", data)
+			} else if !test.notModified && string(data) != want {
+				t.Errorf("GetFile = %q, want %s", data, want)
+			}
+		})
+	}
+}
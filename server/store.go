@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+var (
+	errNotFound       = errors.New("object not found")
+	errBucketNotFound = errors.New("bucket not found")
+	errInvalidName    = errors.New("invalid bucket or key")
+	errInvalidRange   = errors.New("invalid range")
+	errInvalidToken   = errors.New("the continuation token is not valid")
+	errCopyToItself   = errors.New("an object can only be copied onto itself to replace its metadata")
+
+	errInvalidStorageClass = errors.New("invalid storage class")
+	errMetadataTooLarge    = errors.New("user metadata is too large")
+)
+
+// Store keeps the objects uploaded through PutFile.
+type Store interface {
+	// Create starts writing bucket/key, creating the bucket if needed. The
+	// object only becomes visible once the writer is committed.
+	Create(bucket, key string) (ObjectWriter, error)
+	// Open returns a reader for bucket/key and describes it, or errNotFound.
+	Open(bucket, key string) (io.ReadCloser, ObjectInfo, error)
+	// Stat describes bucket/key without reading it.
+	Stat(bucket, key string) (ObjectInfo, error)
+	// Delete removes bucket/key, or returns errNotFound.
+	Delete(bucket, key string) error
+	// List returns the objects of bucket whose key starts with prefix,
+	// sorted by key, or errBucketNotFound.
+	List(bucket, prefix string) ([]ObjectInfo, error)
+	CreateBucket(bucket string) error
+}
+
+// ObjectWriter receives the bytes of one upload. Commit stores them with
+// meta, which is only complete once every byte has been checksummed, if cond
+// holds for the object they replace. Nothing else changes the object between
+// the check and the store, and nothing is stored when the check fails.
+type ObjectWriter interface {
+	io.Writer
+	Commit(meta ObjectMeta, cond object.Preconditions) error
+	Abort() error
+}
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ObjectMeta
+}
+
+// ObjectMeta is what an upload tells about an object besides its bytes, and
+// the checksums of those bytes.
+type ObjectMeta struct {
+	ContentType  string            `json:"content_type"`
+	UserMetadata map[string]string `json:"user_metadata,omitempty"`
+	StorageClass string            `json:"storage_class"`
+	object.Checksums
+}
+
+// storageClasses lists the storage classes S3 accepts. They only label
+// objects, every class is served alike.
+var storageClasses = map[string]bool{
+	"STANDARD":            true,
+	"REDUCED_REDUNDANCY":  true,
+	"STANDARD_IA":         true,
+	"ONEZONE_IA":          true,
+	"INTELLIGENT_TIERING": true,
+	"GLACIER":             true,
+	"GLACIER_IR":          true,
+	"DEEP_ARCHIVE":        true,
+	"OUTPOSTS":            true,
+	"EXPRESS_ONEZONE":     true,
+}
+
+// maxUserMetadataSize is the S3 limit on the keys and values of the user
+// metadata of an object.
+const maxUserMetadataSize = 2 * 1024
+
+// newObjectMeta validates the metadata of an upload and fills in the S3
+// defaults. User metadata keys are case insensitive and kept in lower case.
+func newObjectMeta(contentType string, userMetadata map[string]string, storageClass string) (ObjectMeta, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+	if !storageClasses[storageClass] {
+		return ObjectMeta{}, fmt.Errorf("%w: %s", errInvalidStorageClass, storageClass)
+	}
+	meta := ObjectMeta{ContentType: contentType, StorageClass: storageClass}
+	size := 0
+	for name, value := range userMetadata {
+		if meta.UserMetadata == nil {
+			meta.UserMetadata = make(map[string]string, len(userMetadata))
+		}
+		meta.UserMetadata[strings.ToLower(name)] = value
+		size += len(name) + len(value)
+	}
+	if size > maxUserMetadataSize {
+		return ObjectMeta{}, fmt.Errorf("%w: %d Bytes, at most %d are allowed", errMetadataTooLarge, size, maxUserMetadataSize)
+	}
+	return meta, nil
+}
+
+func newStore(backend, dataDir string) (Store, error) {
+	switch backend {
+	case "memory":
+		return newMemoryStore(), nil
+	case "disk":
+		return newDiskStore(dataDir)
+	case "discard":
+		return newDiscardStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q - choose from [memory, disk, discard]", backend)
+	}
+}
+
+func objectName(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// maxDeleteKeys caps the keys of one batch delete, as S3 does.
+const maxDeleteKeys = 1000
+
+// deleteObject removes bucket/key. Like S3 it succeeds when there is none.
+func deleteObject(store Store, bucket, key string) error {
+	if err := store.Delete(bucket, key); err != nil && !errors.Is(err, errNotFound) {
+		return err
+	}
+	return nil
+}
+
+// copyObject copies an object within store and returns the info of the copy.
+// The copy gets meta, or the metadata of the source when meta is nil, and the
+// checksums of the bytes copied: its ETag is their MD5 even when the source
+// was assembled from parts.
+func copyObject(store Store, srcBucket, srcKey, dstBucket, dstKey string, meta *ObjectMeta) (ObjectInfo, error) {
+	if srcBucket == dstBucket && srcKey == dstKey && meta == nil {
+		return ObjectInfo{}, errCopyToItself
+	}
+	r, info, err := store.Open(srcBucket, srcKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer r.Close()
+	if meta == nil {
+		meta = &info.ObjectMeta
+	}
+	w, err := store.Create(dstBucket, dstKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	d := object.NewDigester(true, info.SHA256 != "")
+	if _, err := io.Copy(io.MultiWriter(w, d), r); err != nil {
+		w.Abort()
+		return ObjectInfo{}, err
+	}
+	copied := *meta
+	copied.Checksums = d.Sum()
+	if err := w.Commit(copied, object.Preconditions{}); err != nil {
+		return ObjectInfo{}, err
+	}
+	return store.Stat(dstBucket, dstKey)
+}
+
+// maxListKeys caps the entries of one listing page, as S3 does.
+const maxListKeys = 1000
+
+// The continuation token of a truncated listing encodes the last entry
+// returned, which the next page starts after.
+func continuationToken(next string) string {
+	return base64.URLEncoding.EncodeToString([]byte(next))
+}
+
+func parseContinuationToken(token string) (string, error) {
+	next, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", errInvalidToken
+	}
+	return string(next), nil
+}
+
+// listPage applies S3 ListObjectsV2 semantics on top of Store.List: keys
+// sharing the part after prefix up to delimiter are rolled up into common
+// prefixes, and at most maxKeys entries after startAfter are returned. When
+// more entries match the listing is truncated and next is the entry to resume
+// after: the last one returned, or startAfter when maxKeys is 0.
+func listPage(store Store, bucket, prefix, delimiter, startAfter string, maxKeys int) (objects []ObjectInfo, prefixes []string, next string, truncated bool, err error) {
+	all, err := store.List(bucket, prefix)
+	if err != nil {
+		return nil, nil, "", false, err
+	}
+	next = startAfter
+	count := 0
+	for _, obj := range all {
+		if obj.Key <= startAfter {
+			continue
+		}
+		entry := obj.Key
+		isPrefix := false
+		if delimiter != "" {
+			if i := strings.Index(obj.Key[len(prefix):], delimiter); i >= 0 {
+				entry = obj.Key[:len(prefix)+i+len(delimiter)]
+				isPrefix = true
+				if entry <= startAfter || (len(prefixes) > 0 && prefixes[len(prefixes)-1] == entry) {
+					continue
+				}
+			}
+		}
+		if count == maxKeys {
+			return objects, prefixes, next, true, nil
+		}
+		if isPrefix {
+			prefixes = append(prefixes, entry)
+		} else {
+			objects = append(objects, obj)
+		}
+		next = entry
+		count++
+	}
+	return objects, prefixes, "", false, nil
+}
+
+// memoryStore keeps every object in a map, which suits small test suites.
+type memoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*memoryObject
+}
+
+type memoryObject struct {
+	data     []byte
+	modified time.Time
+	meta     ObjectMeta
+}
+
+func (obj *memoryObject) info(key string) ObjectInfo {
+	return ObjectInfo{Key: key, Size: int64(len(obj.data)), LastModified: obj.modified, ObjectMeta: obj.meta}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: make(map[string]map[string]*memoryObject)}
+}
+
+func (m *memoryStore) Create(bucket, key string) (ObjectWriter, error) {
+	return &memoryWriter{store: m, bucket: bucket, key: key}, nil
+}
+
+func (m *memoryStore) Open(bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	m.mu.RLock()
+	obj, ok := m.buckets[bucket][key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, errNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.info(key), nil
+}
+
+func (m *memoryStore) Stat(bucket, key string) (ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.buckets[bucket][key]
+	if !ok {
+		return ObjectInfo{}, errNotFound
+	}
+	return obj.info(key), nil
+}
+
+func (m *memoryStore) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucket][key]; !ok {
+		return errNotFound
+	}
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *memoryStore) List(bucket, prefix string) ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, errBucketNotFound
+	}
+	var infos []ObjectInfo
+	for key, obj := range objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info(key))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (m *memoryStore) CreateBucket(bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucket]; !ok {
+		m.buckets[bucket] = make(map[string]*memoryObject)
+	}
+	return nil
+}
+
+type memoryWriter struct {
+	bytes.Buffer
+	store  *memoryStore
+	bucket string
+	key    string
+}
+
+func (w *memoryWriter) Commit(meta ObjectMeta, cond object.Preconditions) error {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	var current *ObjectInfo
+	if obj, ok := w.store.buckets[w.bucket][w.key]; ok {
+		info := obj.info(w.key)
+		current = &info
+	}
+	if err := evaluate(cond, current, false); err != nil {
+		return err
+	}
+	if _, ok := w.store.buckets[w.bucket]; !ok {
+		w.store.buckets[w.bucket] = make(map[string]*memoryObject)
+	}
+	w.store.buckets[w.bucket][w.key] = &memoryObject{data: w.Bytes(), modified: time.Now(), meta: meta}
+	return nil
+}
+
+func (w *memoryWriter) Abort() error {
+	w.Reset()
+	return nil
+}
+
+// discardStore throws uploaded bytes away and only remembers sizes, so pure
+// load tests can still read back an object of the right length. The bytes
+// read back are not those uploaded, so Open reports no checksums for them
+// while Stat keeps those of the upload.
+type discardStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]ObjectInfo
+}
+
+func newDiscardStore() *discardStore {
+	return &discardStore{buckets: make(map[string]map[string]ObjectInfo)}
+}
+
+func (d *discardStore) Create(bucket, key string) (ObjectWriter, error) {
+	return &discardWriter{store: d, bucket: bucket, key: key}, nil
+}
+
+func (d *discardStore) Open(bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := d.Stat(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	info.Checksums = object.Checksums{}
+	return io.NopCloser(&syntheticReader{remaining: info.Size}), info, nil
+}
+
+func (d *discardStore) Stat(bucket, key string) (ObjectInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	info, ok := d.buckets[bucket][key]
+	if !ok {
+		return ObjectInfo{}, errNotFound
+	}
+	return info, nil
+}
+
+func (d *discardStore) Delete(bucket, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.buckets[bucket][key]; !ok {
+		return errNotFound
+	}
+	delete(d.buckets[bucket], key)
+	return nil
+}
+
+func (d *discardStore) List(bucket, prefix string) ([]ObjectInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	objects, ok := d.buckets[bucket]
+	if !ok {
+		return nil, errBucketNotFound
+	}
+	var infos []ObjectInfo
+	for key, info := range objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (d *discardStore) CreateBucket(bucket string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.buckets[bucket]; !ok {
+		d.buckets[bucket] = make(map[string]ObjectInfo)
+	}
+	return nil
+}
+
+type discardWriter struct {
+	store  *discardStore
+	bucket string
+	key    string
+	size   int64
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+func (w *discardWriter) Commit(meta ObjectMeta, cond object.Preconditions) error {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	var current *ObjectInfo
+	if info, ok := w.store.buckets[w.bucket][w.key]; ok {
+		current = &info
+	}
+	if err := evaluate(cond, current, false); err != nil {
+		return err
+	}
+	if _, ok := w.store.buckets[w.bucket]; !ok {
+		w.store.buckets[w.bucket] = make(map[string]ObjectInfo)
+	}
+	w.store.buckets[w.bucket][w.key] = ObjectInfo{Key: w.key, Size: w.size, LastModified: time.Now(), ObjectMeta: meta}
+	return nil
+}
+
+func (w *discardWriter) Abort() error {
+	return nil
+}
+
+// syntheticReader yields remaining bytes taken from the shared random buffer.
+type syntheticReader struct {
+	remaining int64
+}
+
+func (r *syntheticReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], buffer)
+	}
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// byteRange resolves the length bytes starting at offset of an object of
+// size bytes into the first byte and the number of bytes to read. A zero
+// length reads to the end, and so does a length past the end. A range at the
+// start of an empty object is empty rather than invalid.
+func byteRange(offset, length, size int64) (int64, int64, error) {
+	if offset < 0 || length < 0 {
+		return 0, 0, fmt.Errorf("%w: negative offset or length", errInvalidRange)
+	}
+	if offset == 0 && length == 0 {
+		return 0, size, nil
+	}
+	if offset > 0 && offset >= size {
+		return 0, 0, fmt.Errorf("%w: offset %d is beyond the end of a %d Bytes object", errInvalidRange, offset, size)
+	}
+	if length == 0 || length > size-offset {
+		length = size - offset
+	}
+	return offset, length, nil
+}
+
+// openRange opens the part of an object byteRange selects. It returns the
+// info of the whole object, the first byte and the number of bytes the
+// reader yields.
+func openRange(store Store, bucket, key string, offset, length int64) (io.ReadCloser, ObjectInfo, int64, int64, error) {
+	r, info, err := store.Open(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, 0, 0, err
+	}
+	first, n, err := byteRange(offset, length, info.Size)
+	if err != nil {
+		r.Close()
+		return nil, ObjectInfo{}, 0, 0, err
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err = seeker.Seek(first, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, r, first)
+	}
+	if err != nil {
+		r.Close()
+		return nil, ObjectInfo{}, 0, 0, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, n), r}, info, first, n, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+// diskStore keeps objects as files under root/<bucket>/<key>, for images
+// that do not fit in memory. Uploads are staged in root/.mocks3/tmp and
+// renamed into place on commit. The metadata of an object is kept as JSON in
+// root/.mocks3/meta/<bucket>/<key>, objects without one get the defaults.
+// Commits and deletes are serialized so that preconditions are evaluated
+// against the object a commit replaces.
+type diskStore struct {
+	root string
+	mu   sync.Mutex
+}
+
+func newDiskStore(root string) (*diskStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, ".mocks3", "tmp"), 0o755); err != nil {
+		return nil, err
+	}
+	return &diskStore{root: root}, nil
+}
+
+// validBucket rejects names that would escape root or clash with .mocks3.
+func validBucket(bucket string) bool {
+	return bucket != "" && !strings.HasPrefix(bucket, ".") && !strings.ContainsAny(bucket, `/\`)
+}
+
+// path maps bucket/key to its file. Keys that the file system would not keep
+// apart, such as a/ and a, a//b and a/b or a/../b and b, are rejected.
+func (d *diskStore) path(bucket, key string) (string, error) {
+	if !validBucket(bucket) && bucket != multipartBucket {
+		return "", errInvalidName
+	}
+	if strings.HasPrefix(key, "/") || pathpkg.Clean(key) != key {
+		return "", errInvalidName
+	}
+	name := filepath.Join(d.root, bucket, filepath.FromSlash(key))
+	if !strings.HasPrefix(name, filepath.Join(d.root, bucket)+string(filepath.Separator)) {
+		return "", errInvalidName
+	}
+	return name, nil
+}
+
+// metaPath returns where the metadata of bucket/key, which path accepts, is
+// kept.
+func (d *diskStore) metaPath(bucket, key string) string {
+	return filepath.Join(d.root, ".mocks3", "meta", bucket, filepath.FromSlash(key))
+}
+
+func (d *diskStore) readMeta(bucket, key string) ObjectMeta {
+	var meta ObjectMeta
+	data, err := os.ReadFile(d.metaPath(bucket, key))
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	if err != nil {
+		meta, _ = newObjectMeta("", nil, "")
+	}
+	return meta
+}
+
+func (d *diskStore) Create(bucket, key string) (ObjectWriter, error) {
+	name, err := d.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Join(d.root, ".mocks3", "tmp"), "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &diskWriter{File: f, store: d, bucket: bucket, key: key, name: name, metaName: d.metaPath(bucket, key)}, nil
+}
+
+func (d *diskStore) Open(bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	name, err := d.path(bucket, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil, ObjectInfo{}, errNotFound
+	} else if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, ObjectInfo{}, errNotFound
+	}
+	return f, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ObjectMeta: d.readMeta(bucket, key)}, nil
+}
+
+func (d *diskStore) Stat(bucket, key string) (ObjectInfo, error) {
+	name, err := d.path(bucket, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) || (err == nil && info.IsDir()) {
+		return ObjectInfo{}, errNotFound
+	} else if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ObjectMeta: d.readMeta(bucket, key)}, nil
+}
+
+func (d *diskStore) Delete(bucket, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.Stat(bucket, key); err != nil {
+		return err
+	}
+	name, _ := d.path(bucket, key)
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	if err := os.Remove(d.metaPath(bucket, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	removeEmptyDirs(filepath.Dir(name), filepath.Join(d.root, bucket))
+	removeEmptyDirs(filepath.Dir(d.metaPath(bucket, key)), filepath.Join(d.root, ".mocks3", "meta", bucket))
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including, top
+// while they are empty, so that a deleted a/b leaves room for an object a.
+func removeEmptyDirs(dir, top string) {
+	for dir != top && strings.HasPrefix(dir, top) && os.Remove(dir) == nil {
+		dir = filepath.Dir(dir)
+	}
+}
+
+// clashError reports a key running into another object on disk, a file
+// where a directory of the key should be or a directory where its file should
+// be, as errInvalidName. Depending on the file system, renaming a file onto a
+// directory fails with EISDIR, EEXIST or ENOTEMPTY.
+func clashError(err error) error {
+	if errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.EISDIR) || errors.Is(err, os.ErrExist) || errors.Is(err, syscall.ENOTEMPTY) {
+		return fmt.Errorf("%w: the key clashes with another object on disk", errInvalidName)
+	}
+	return err
+}
+
+func (d *diskStore) List(bucket, prefix string) ([]ObjectInfo, error) {
+	if !validBucket(bucket) {
+		return nil, errInvalidName
+	}
+	dir := filepath.Join(d.root, bucket)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, errBucketNotFound
+	}
+	var infos []ObjectInfo
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ObjectMeta: d.readMeta(bucket, key)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (d *diskStore) CreateBucket(bucket string) error {
+	if !validBucket(bucket) {
+		return errInvalidName
+	}
+	return os.MkdirAll(filepath.Join(d.root, bucket), 0o755)
+}
+
+type diskWriter struct {
+	*os.File
+	store    *diskStore
+	bucket   string
+	key      string
+	name     string
+	metaName string
+}
+
+// Commit moves the metadata into place before the object, so a reader never
+// sees the new object with the metadata of the one it replaces.
+func (w *diskWriter) Commit(meta ObjectMeta, cond object.Preconditions) error {
+	if err := w.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	var current *ObjectInfo
+	if info, err := w.store.Stat(w.bucket, w.key); err == nil {
+		current = &info
+	} else if !errors.Is(err, errNotFound) {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := evaluate(cond, current, false); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := w.commitMeta(meta); err != nil {
+		os.Remove(w.File.Name())
+		return clashError(err)
+	}
+	err := os.MkdirAll(filepath.Dir(w.name), 0o755)
+	if err == nil {
+		err = os.Rename(w.File.Name(), w.name)
+	}
+	if err != nil {
+		os.Remove(w.File.Name())
+		return clashError(err)
+	}
+	return nil
+}
+
+func (w *diskWriter) commitMeta(meta ObjectMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Join(w.store.root, ".mocks3", "tmp"), "meta-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(w.metaName), 0o755)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), w.metaName)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (w *diskWriter) Abort() error {
+	w.Close()
+	return os.Remove(w.File.Name())
+}
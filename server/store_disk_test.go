@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+func TestDiskStorePath(t *testing.T) {
+	root := t.TempDir()
+	store, err := newDiskStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		bucket, key string
+		// want is relative to root, empty when the name is rejected.
+		want string
+	}{
+		{bucket: "b", key: "k", want: "b/k"},
+		{bucket: "b", key: "a/b/c", want: "b/a/b/c"},
+		{bucket: multipartBucket, key: "upload/1", want: multipartBucket + "/upload/1"},
+		{bucket: "b", key: "../k"},
+		{bucket: "b", key: "../../etc/passwd"},
+		{bucket: "b", key: "a/../../c/k"},
+		{bucket: "b", key: ""},
+		{bucket: "b", key: "."},
+		{bucket: "b", key: "a/.."},
+		// Keys the file system would store under the name of another.
+		{bucket: "b", key: "a/../k"},
+		{bucket: "b", key: "a/"},
+		{bucket: "b", key: "a//b"},
+		{bucket: "b", key: "./k"},
+		{bucket: "b", key: "/k"},
+		{bucket: "", key: "k"},
+		{bucket: "..", key: "k"},
+		{bucket: ".mocks3", key: "meta/b/k"},
+		{bucket: "a/b", key: "k"},
+		{bucket: `a\b`, key: "k"},
+	}
+	for _, test := range tests {
+		t.Run(test.bucket+"/"+test.key, func(t *testing.T) {
+			path, err := store.path(test.bucket, test.key)
+			if test.want == "" {
+				if !errors.Is(err, errInvalidName) {
+					t.Errorf("path = %q, %v, want %v", path, err, errInvalidName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("path error = %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(test.want)); path != want {
+				t.Errorf("path = %q, want %q", path, want)
+			}
+		})
+	}
+}
+
+// TestDiskStoreClashes checks that an object cannot be stored where another
+// one needs a directory, or the other way round, and that deleting an object
+// makes room again.
+func TestDiskStoreClashes(t *testing.T) {
+	store, err := newDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(key string) error {
+		w, err := store.Create("b", key)
+		if err != nil {
+			return err
+		}
+		w.Write([]byte(key))
+		return w.Commit(ObjectMeta{}, object.Preconditions{})
+	}
+
+	if err := commit("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit("a/b"); !errors.Is(err, errInvalidName) {
+		t.Errorf("storing a/b under the object a: error = %v, want %v", err, errInvalidName)
+	}
+	if _, err := store.Stat("b", "a/b"); !errors.Is(err, errNotFound) {
+		t.Errorf("Stat of a/b under the object a: error = %v, want %v", err, errNotFound)
+	}
+	if _, _, err := store.Open("b", "a/b"); !errors.Is(err, errNotFound) {
+		t.Errorf("Open of a/b under the object a: error = %v, want %v", err, errNotFound)
+	}
+
+	if err := store.Delete("b", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit("a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit("a"); !errors.Is(err, errInvalidName) {
+		t.Errorf("storing a over the object a/b/c: error = %v, want %v", err, errInvalidName)
+	}
+	if err := store.Delete("b", "a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commit("a"); err != nil {
+		t.Errorf("storing a once a/b/c is deleted: %v", err)
+	}
+	if objects, err := store.List("b", ""); err != nil || len(objects) != 1 || objects[0].Key != "a" {
+		t.Errorf("List = %v, %v, want a", objects, err)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	object "github.com/JooyoungPark73/mocks3/object"
+)
+
+func TestByteRange(t *testing.T) {
+	tests := []struct {
+		offset, length, size int64
+		first, n             int64
+		err                  error
+	}{
+		{offset: 0, length: 0, size: 10, first: 0, n: 10},
+		{offset: 2, length: 3, size: 10, first: 2, n: 3},
+		{offset: 2, length: 0, size: 10, first: 2, n: 8},
+		{offset: 8, length: 5, size: 10, first: 8, n: 2},
+		{offset: 9, length: 1, size: 10, first: 9, n: 1},
+		{offset: 0, length: 0, size: 0, first: 0, n: 0},
+		{offset: 0, length: 5, size: 0, first: 0, n: 0},
+		{offset: 10, length: 1, size: 10, err: errInvalidRange},
+		{offset: 1, length: 0, size: 0, err: errInvalidRange},
+		{offset: -1, length: 0, size: 10, err: errInvalidRange},
+		{offset: 0, length: -1, size: 10, err: errInvalidRange},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d+%d of %d", test.offset, test.length, test.size), func(t *testing.T) {
+			first, n, err := byteRange(test.offset, test.length, test.size)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("byteRange error = %v, want %v", err, test.err)
+			}
+			if first != test.first || n != test.n {
+				t.Errorf("byteRange = %d, %d, want %d, %d", first, n, test.first, test.n)
+			}
+		})
+	}
+}
+
+func newListStore(t *testing.T, bucket string, keys ...string) Store {
+	t.Helper()
+	store := newMemoryStore()
+	if err := store.CreateBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		w, err := store.Create(bucket, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Commit(ObjectMeta{}, object.Preconditions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestListPage(t *testing.T) {
+	store := newListStore(t, "b", "a", "b/1", "b/2", "c/x/1", "c/y", "d")
+	tests := []struct {
+		name                          string
+		prefix, delimiter, startAfter string
+		maxKeys                       int
+		keys, prefixes                []string
+		next                          string
+		truncated                     bool
+	}{
+		{
+			name:    "everything",
+			maxKeys: 1000,
+			keys:    []string{"a", "b/1", "b/2", "c/x/1", "c/y", "d"},
+		},
+		{
+			name:    "prefix",
+			prefix:  "b/",
+			maxKeys: 1000,
+			keys:    []string{"b/1", "b/2"},
+		},
+		{
+			name:      "delimiter",
+			delimiter: "/",
+			maxKeys:   1000,
+			keys:      []string{"a", "d"},
+			prefixes:  []string{"b/", "c/"},
+		},
+		{
+			name:      "prefix and delimiter",
+			prefix:    "c/",
+			delimiter: "/",
+			maxKeys:   1000,
+			keys:      []string{"c/y"},
+			prefixes:  []string{"c/x/"},
+		},
+		{
+			name:       "start after a key",
+			startAfter: "b/1",
+			maxKeys:    1000,
+			keys:       []string{"b/2", "c/x/1", "c/y", "d"},
+		},
+		{
+			name:       "start after a common prefix",
+			delimiter:  "/",
+			startAfter: "b/",
+			maxKeys:    1000,
+			keys:       []string{"d"},
+			prefixes:   []string{"c/"},
+		},
+		{
+			name:      "truncated on a common prefix",
+			delimiter: "/",
+			maxKeys:   2,
+			keys:      []string{"a"},
+			prefixes:  []string{"b/"},
+			next:      "b/",
+			truncated: true,
+		},
+		{
+			name:      "max keys matches exactly",
+			prefix:    "b/",
+			maxKeys:   2,
+			keys:      []string{"b/1", "b/2"},
+			truncated: false,
+		},
+		{
+			name:      "max keys 0 with matches",
+			maxKeys:   0,
+			truncated: true,
+		},
+		{
+			name:       "max keys 0 resumes where it was",
+			startAfter: "b/2",
+			maxKeys:    0,
+			next:       "b/2",
+			truncated:  true,
+		},
+		{
+			name:       "max keys 0 without matches",
+			startAfter: "d",
+			maxKeys:    0,
+		},
+		{
+			name:    "no match",
+			prefix:  "z",
+			maxKeys: 1000,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects, prefixes, next, truncated, err := listPage(store, "b", test.prefix, test.delimiter, test.startAfter, test.maxKeys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, obj := range objects {
+				keys = append(keys, obj.Key)
+			}
+			if !reflect.DeepEqual(keys, test.keys) || !reflect.DeepEqual(prefixes, test.prefixes) {
+				t.Errorf("listed %q and prefixes %q, want %q and %q", keys, prefixes, test.keys, test.prefixes)
+			}
+			if next != test.next || truncated != test.truncated {
+				t.Errorf("next %q truncated %t, want %q %t", next, truncated, test.next, test.truncated)
+			}
+		})
+	}
+}
+
+// TestListPagination walks a listing page by page through continuation
+// tokens, as a client would.
+func TestListPagination(t *testing.T) {
+	store := newListStore(t, "b", "a", "b/1", "b/2", "c/x/1", "c/y", "d")
+	for _, maxKeys := range []int{1, 2, 3} {
+		t.Run(fmt.Sprint(maxKeys), func(t *testing.T) {
+			var entries []string
+			startAfter := ""
+			for page := 0; ; page++ {
+				if page > 10 {
+					t.Fatal("listing does not end")
+				}
+				objects, prefixes, next, truncated, err := listPage(store, "b", "", "/", startAfter, maxKeys)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(objects)+len(prefixes) > maxKeys {
+					t.Fatalf("page of %d entries, want at most %d", len(objects)+len(prefixes), maxKeys)
+				}
+				for _, obj := range objects {
+					entries = append(entries, obj.Key)
+				}
+				entries = append(entries, prefixes...)
+				if !truncated {
+					break
+				}
+				if startAfter, err = parseContinuationToken(continuationToken(next)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sort.Strings(entries)
+			want := []string{"a", "b/", "c/", "d"}
+			if !reflect.DeepEqual(entries, want) {
+				t.Errorf("listed %q, want %q", entries, want)
+			}
+		})
+	}
+}
+
+func TestListPageErrors(t *testing.T) {
+	store := newListStore(t, "b")
+	if _, _, _, _, err := listPage(store, "missing", "", "", "", 1000); !errors.Is(err, errBucketNotFound) {
+		t.Errorf("listing a missing bucket: error = %v, want %v", err, errBucketNotFound)
+	}
+	if _, err := parseContinuationToken("not base64!"); !errors.Is(err, errInvalidToken) {
+		t.Errorf("parsing a malformed token: error = %v, want %v", err, errInvalidToken)
+	}
+}
+
+func putTestObject(t *testing.T, store Store, bucket, key, data string, meta ObjectMeta) {
+	t.Helper()
+	w, err := store.Create(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(meta, object.Preconditions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStoreBackends runs every backend through the life of an object.
+func TestStoreBackends(t *testing.T) {
+	for _, backend := range []string{"memory", "disk", "discard"} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := newStore(backend, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.List("b", ""); !errors.Is(err, errBucketNotFound) {
+				t.Errorf("listing a missing bucket: error = %v, want %v", err, errBucketNotFound)
+			}
+			putTestObject(t, store, "b", "dir/k", "hello", ObjectMeta{ContentType: "text/plain", StorageClass: "STANDARD"})
+
+			info, err := store.Stat("b", "dir/k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Key != "dir/k" || info.Size != 5 || info.ContentType != "text/plain" || info.LastModified.IsZero() {
+				t.Errorf("Stat = %+v, want dir/k of 5 Bytes of text/plain", info)
+			}
+			r, info, err := store.Open("b", "dir/k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The discard backend only keeps the size.
+			if len(data) != 5 || (backend != "discard" && string(data) != "hello") || info.Size != 5 {
+				t.Errorf("Open read %q of an object of %d Bytes, want hello", data, info.Size)
+			}
+
+			w, err := store.Create("b", "aborted")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte("lost"))
+			if err := w.Abort(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Stat("b", "aborted"); !errors.Is(err, errNotFound) {
+				t.Errorf("Stat of an aborted upload: error = %v, want %v", err, errNotFound)
+			}
+
+			putTestObject(t, store, "b", "dir/k", "bye", ObjectMeta{})
+			putTestObject(t, store, "b", "other", "x", ObjectMeta{})
+			if info, err := store.Stat("b", "dir/k"); err != nil || info.Size != 3 {
+				t.Errorf("Stat after an overwrite = %d Bytes, %v, want 3 Bytes", info.Size, err)
+			}
+			objects, err := store.List("b", "dir/")
+			if err != nil || len(objects) != 1 || objects[0].Key != "dir/k" {
+				t.Errorf("List(dir/) = %v, %v, want dir/k", objects, err)
+			}
+
+			if err := store.Delete("b", "dir/k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Stat("b", "dir/k"); !errors.Is(err, errNotFound) {
+				t.Errorf("Stat after Delete: error = %v, want %v", err, errNotFound)
+			}
+			if err := store.Delete("b", "dir/k"); !errors.Is(err, errNotFound) {
+				t.Errorf("deleting twice: error = %v, want %v", err, errNotFound)
+			}
+		})
+	}
+}
@@ -3,33 +3,43 @@ package mocks3
 import (
 	"crypto/rand"
 	"flag"
-	"log"
-	"math"
+	"fmt"
+	"sync"
 	"time"
+
+	latency "github.com/JooyoungPark73/mocks3/latency"
 )
 
 var (
 	Addr          = flag.String("addr", "localhost:30000", "the address to connect to")
 	Verbosity     = flag.String("verbosity", "info", "Logging verbosity - choose from [info, debug, trace]")
 	TestIteration = flag.Int("iteration", 100, "Number of iterations to run")
-)
+	LatencyModel  = flag.String("latency-model", "", "Latency model JSON file, defaults to $MOCKS3_LATENCY_MODEL or the built-in curve")
+	Timeout       = flag.Duration("timeout", 0, "Deadline of each request, 0 means no deadline")
+	Strict        = flag.Bool("strict", false, "Fail the benchmark when a request misses its target time")
+	Checksum      = flag.String("checksum", "CRC32C", "Checksum of uploads on top of the MD5 ETag of objects - choose from [CRC32C, SHA256]")
+	Parts         = flag.Int("parts", 1, "Number of ranged requests each benchmark GET is split into, fetched in parallel")
+	PartSize      = flag.Int64("part-size", 0, "Part size in Bytes of multipart benchmark PUTs, with -parts of them in flight, 0 for single stream PUTs")
+	RetryAttempts = flag.Int("retry-attempts", 1, "Attempts per request including the first, 1 disables retries")
+	RetryBase     = flag.Duration("retry-base", 100*time.Millisecond, "Backoff before the first retry, doubled on every retry")
+	RetryCap      = flag.Duration("retry-cap", 20*time.Second, "Upper bound of the backoff between retries")
+	RetryJitter   = flag.String("retry-jitter", "full", "Backoff jitter - choose from [none, full, equal, decorrelated]")
+	RetryCodes    = flag.String("retry-codes", "Unavailable,ResourceExhausted,Internal", "Comma separated gRPC status codes to retry")
 
-func GetTimeToSleep(commType string, fileSize int64) time.Duration {
-	// Sourced from: https://github.com/vhive-serverless/MockS3/blob/main/mocks3/mock_io_functions.py
-	// [  0.12018868   1.11999534 111.24820149]
-	// a * np.exp(b * np.log10(x_point)) + c
-
-	latencyPower := 120.18868*math.Exp(1.11999534*math.Log10(float64(fileSize))) + 111248.20149
+	model     *latency.Model
+	modelErr  error
+	modelOnce sync.Once
+)
 
-	if commType == "GET" {
-		latencyPower = latencyPower * 0.33
-	} else if commType == "PUT" {
-		latencyPower = latencyPower * 0.67
-	} else {
-		log.Panic("Invalid communication type")
-	}
-	sleepTime := time.Duration(latencyPower) * time.Microsecond
-	return sleepTime
+// Model returns the latency model selected by -latency-model, loading it on
+// first use. A model that cannot be loaded gives the same error every time.
+func Model() (*latency.Model, error) {
+	modelOnce.Do(func() {
+		if model, modelErr = latency.Resolve(*LatencyModel); modelErr != nil {
+			modelErr = fmt.Errorf("could not load latency model: %w", modelErr)
+		}
+	})
+	return model, modelErr
 }
 
 func CreateRandomObject(size int64) []byte {